@@ -0,0 +1,360 @@
+package crawlbase
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	SeverityInfo   = "info"
+	SeverityLow    = "low"
+	SeverityMedium = "medium"
+	SeverityHigh   = "high"
+)
+
+var severityOrder = map[string]int{SeverityHigh: 0, SeverityMedium: 1, SeverityLow: 2, SeverityInfo: 3}
+
+type AuditFinding struct {
+	Check    string
+	Severity string
+	Detail   string
+	Url      string `json:",omitempty"`
+}
+
+// HostFinding is a finding of a host with the pages it occurs on
+type HostFinding struct {
+	Check    string
+	Severity string
+	Detail   string
+	Urls     []string
+}
+
+type HostAudit struct {
+	Host     string
+	Findings []HostFinding
+}
+
+// CSP maps directive names to their sources
+type CSP map[string][]string
+
+// hsts max-age below half a year is considered short
+const minHSTSMaxAge = 15768000
+
+func ParseCSP(policy string) CSP {
+	csp := CSP{}
+	for _, directive := range strings.Split(policy, ";") {
+		fields := strings.Fields(directive)
+		if len(fields) == 0 {
+			continue
+		}
+		name := strings.ToLower(fields[0])
+		if _, exists := csp[name]; exists {
+			// only the first occurrence of a directive is used
+			continue
+		}
+		csp[name] = fields[1:]
+	}
+	return csp
+}
+
+// Sources returns the sources of a fetch directive, falling back to
+// default-src
+func (csp CSP) Sources(directive string) ([]string, bool) {
+	if sources, ok := csp[directive]; ok {
+		return sources, true
+	}
+	sources, ok := csp["default-src"]
+	return sources, ok
+}
+
+// EvaluateCSP returns the weaknesses of a policy
+func EvaluateCSP(csp CSP) []AuditFinding {
+	findings := []AuditFinding{}
+	add := func(severity, detail string) {
+		findings = append(findings, AuditFinding{Check: "csp", Severity: severity, Detail: detail})
+	}
+
+	scriptSrc, hasScriptSrc := csp.Sources("script-src")
+	if !hasScriptSrc {
+		add(SeverityMedium, "no script-src or default-src")
+	}
+	hasNonceOrHash := false
+	for _, src := range scriptSrc {
+		lower := strings.ToLower(src)
+		if strings.HasPrefix(lower, "'nonce-") || strings.HasPrefix(lower, "'sha") {
+			hasNonceOrHash = true
+		}
+	}
+	for _, src := range scriptSrc {
+		switch strings.ToLower(src) {
+		case "'unsafe-inline'":
+			// ignored by browsers supporting nonces and hashes
+			if !hasNonceOrHash {
+				add(SeverityHigh, "script-src allows 'unsafe-inline'")
+			}
+		case "'unsafe-eval'":
+			add(SeverityMedium, "script-src allows 'unsafe-eval'")
+		case "*", "http:", "https:", "data:":
+			add(SeverityHigh, "script-src allows "+src)
+		}
+		if strings.HasPrefix(strings.ToLower(src), "http://") {
+			add(SeverityMedium, "script-src allows insecure "+src)
+		}
+	}
+
+	if objectSrc, ok := csp.Sources("object-src"); !ok || !ContainsString(objectSrc, "'none'") {
+		add(SeverityLow, "object-src is not 'none'")
+	}
+	if _, ok := csp["base-uri"]; !ok {
+		add(SeverityLow, "no base-uri")
+	}
+	return findings
+}
+
+// isAuthenticated reports whether the request carried credentials or the
+// response sets a cookie
+func isAuthenticated(page *Page) bool {
+	if page.Request != nil && page.Request.Header != nil {
+		if page.Request.Header.Get("Cookie") != "" || page.Request.Header.Get("Authorization") != "" {
+			return true
+		}
+	}
+	return page.Response != nil && len(page.Response.Cookies) > 0
+}
+
+// AuditPage checks the security headers, cookies and mixed content of a
+// page without sending requests
+func AuditPage(page *Page) []AuditFinding {
+	findings := []AuditFinding{}
+	if page.Response == nil {
+		return findings
+	}
+	pageUrl, err := url.Parse(page.URL)
+	if err != nil {
+		return findings
+	}
+	header := page.Response.Header
+	if header == nil {
+		header = http.Header{}
+	}
+	add := func(check, severity, detail string) {
+		findings = append(findings, AuditFinding{Check: check, Severity: severity, Detail: detail, Url: page.URL})
+	}
+	isHttps := pageUrl.Scheme == "https"
+	isHtml := page.Response.ContentMIME == "" || page.Response.ContentMIME == "text/html"
+
+	if isHttps {
+		hsts := header.Get("Strict-Transport-Security")
+		if hsts == "" {
+			add("hsts", SeverityMedium, "Strict-Transport-Security missing")
+		} else if maxAge := hstsMaxAge(hsts); maxAge < minHSTSMaxAge {
+			add("hsts", SeverityLow, "Strict-Transport-Security max-age "+strconv.Itoa(maxAge)+" is short")
+		}
+	}
+
+	csp := ParseCSP(strings.Join(header["Content-Security-Policy"], ";"))
+	if isHtml {
+		if len(csp) == 0 {
+			if header.Get("Content-Security-Policy-Report-Only") != "" {
+				add("csp", SeverityLow, "Content-Security-Policy is report only")
+			} else {
+				add("csp", SeverityMedium, "Content-Security-Policy missing")
+			}
+		} else {
+			for _, f := range EvaluateCSP(csp) {
+				add(f.Check, f.Severity, f.Detail)
+			}
+		}
+
+		xfo := strings.ToUpper(strings.TrimSpace(header.Get("X-Frame-Options")))
+		_, hasFrameAncestors := csp["frame-ancestors"]
+		if xfo == "" && !hasFrameAncestors {
+			add("clickjacking", SeverityMedium, "X-Frame-Options and frame-ancestors missing")
+		} else if xfo != "" && xfo != "DENY" && xfo != "SAMEORIGIN" {
+			add("clickjacking", SeverityLow, "invalid X-Frame-Options "+xfo)
+		}
+	}
+
+	if !strings.EqualFold(strings.TrimSpace(header.Get("X-Content-Type-Options")), "nosniff") {
+		add("content-type-options", SeverityLow, "X-Content-Type-Options nosniff missing")
+	}
+
+	referrer := strings.ToLower(header.Get("Referrer-Policy"))
+	if referrer == "" && isHtml {
+		add("referrer-policy", SeverityLow, "Referrer-Policy missing")
+	} else if strings.Contains(referrer, "unsafe-url") || strings.Contains(referrer, "no-referrer-when-downgrade") {
+		add("referrer-policy", SeverityLow, "Referrer-Policy leaks full urls: "+referrer)
+	}
+
+	acao := strings.TrimSpace(header.Get("Access-Control-Allow-Origin"))
+	credentials := strings.EqualFold(header.Get("Access-Control-Allow-Credentials"), "true")
+	switch {
+	case acao == "*" && credentials:
+		// browsers refuse credentials for a wildcard origin
+		add("cors", SeverityLow, "wildcard origin with credentials")
+	case acao == "*":
+		add("cors", SeverityInfo, "wildcard origin")
+	case acao == "null":
+		add("cors", SeverityMedium, "null origin allowed")
+	case acao != "" && credentials && strings.HasPrefix(acao, "http://"):
+		add("cors", SeverityMedium, "credentials allowed for insecure origin "+acao)
+	}
+
+	if isAuthenticated(page) && page.Response.StatusCode == 200 {
+		cacheControl := strings.ToLower(header.Get("Cache-Control"))
+		if !strings.Contains(cacheControl, "no-store") && !strings.Contains(cacheControl, "private") {
+			add("cache", SeverityMedium, "authenticated response may be cached: Cache-Control "+strconv.Quote(cacheControl))
+		}
+	}
+
+	for _, c := range page.Response.Cookies {
+		if isHttps && !c.Secure {
+			add("cookie", SeverityMedium, c.Name+" without Secure")
+		}
+		if !c.Httponly {
+			add("cookie", SeverityLow, c.Name+" without HttpOnly")
+		}
+		if c.SameSite == "" {
+			add("cookie", SeverityLow, c.Name+" without SameSite")
+		} else if c.SameSite == "None" && !c.Secure {
+			add("cookie", SeverityMedium, c.Name+" SameSite=None without Secure")
+		}
+	}
+
+	if isHttps {
+		for _, res := range page.RespInfo.Ressources {
+			if !strings.HasPrefix(strings.ToLower(res.Url), "http://") {
+				continue
+			}
+			switch mixedContentType(res) {
+			case "active":
+				add("mixed-content", SeverityHigh, "active content over http: "+res.Url)
+			case "passive":
+				add("mixed-content", SeverityLow, "passive content over http: "+res.Url)
+			}
+		}
+		for _, form := range page.RespInfo.Forms {
+			if strings.HasPrefix(strings.ToLower(form.Url), "http://") {
+				add("mixed-content", SeverityMedium, "form posts over http: "+form.Url)
+			}
+		}
+	}
+
+	return findings
+}
+
+// mixedContentType returns active or passive for ressources the browser
+// loads, urls found in scripts, source maps or a meta refresh are not
+// loaded and return ""
+func mixedContentType(res Ressource) string {
+	if res.Type == "import" {
+		return "active"
+	}
+	if res.Type == "url" && (res.Tag == "css" || res.Tag == "style" || res.Source == "style") {
+		// images and fonts of stylesheets
+		return "passive"
+	}
+	switch res.Tag {
+	case "script", "iframe", "frame", "object", "embed":
+		return "active"
+	case "img", "video", "audio", "source", "track", "input", "image", "use":
+		return "passive"
+	case "link":
+		rel := strings.ToLower(res.Rel)
+		if strings.Contains(rel, "stylesheet") || strings.Contains(rel, "modulepreload") {
+			return "active"
+		}
+		if strings.Contains(rel, "icon") {
+			return "passive"
+		}
+	}
+	return ""
+}
+
+func hstsMaxAge(hsts string) int {
+	for _, directive := range strings.Split(hsts, ";") {
+		kv := strings.SplitN(strings.TrimSpace(directive), "=", 2)
+		if len(kv) == 2 && strings.EqualFold(kv[0], "max-age") {
+			maxAge, err := strconv.Atoi(strings.Trim(kv[1], `"`))
+			if err == nil {
+				return maxAge
+			}
+		}
+	}
+	return 0
+}
+
+// AddAuditFindings merges findings into the report of their host
+func AddAuditFindings(hosts map[string]*HostAudit, findings []AuditFinding) {
+	for _, f := range findings {
+		host := ""
+		if u, err := url.Parse(f.Url); err == nil {
+			host = u.Host
+		}
+		audit, ok := hosts[host]
+		if !ok {
+			audit = &HostAudit{Host: host, Findings: []HostFinding{}}
+			hosts[host] = audit
+		}
+		merged := false
+		for i := range audit.Findings {
+			hf := &audit.Findings[i]
+			if hf.Check == f.Check && hf.Detail == f.Detail {
+				if !ContainsString(hf.Urls, f.Url) {
+					hf.Urls = append(hf.Urls, f.Url)
+				}
+				merged = true
+			}
+		}
+		if !merged {
+			audit.Findings = append(audit.Findings, HostFinding{Check: f.Check, Severity: f.Severity, Detail: f.Detail, Urls: []string{f.Url}})
+		}
+	}
+}
+
+func auditReport(hosts map[string]*HostAudit) []HostAudit {
+	report := []HostAudit{}
+	for _, audit := range hosts {
+		findings := audit.Findings
+		sort.SliceStable(findings, func(i, j int) bool {
+			return severityOrder[findings[i].Severity] < severityOrder[findings[j].Severity]
+		})
+		report = append(report, *audit)
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].Host < report[j].Host })
+	return report
+}
+
+// AuditReportFromPages audits stored pages and groups the findings by host
+func AuditReportFromPages(pages []*Page) []HostAudit {
+	hosts := map[string]*HostAudit{}
+	for _, page := range pages {
+		AddAuditFindings(hosts, AuditPage(page))
+	}
+	return auditReport(hosts)
+}
+
+// AuditPage stores the findings on the page and in the host report of the
+// crawler
+func (c *Crawler) AuditPage(page *Page) {
+	page.Audit = AuditPage(page)
+	if c.audits == nil {
+		c.audits = map[string]*HostAudit{}
+	}
+	AddAuditFindings(c.audits, page.Audit)
+}
+
+func (c *Crawler) AuditReport() []HostAudit {
+	return auditReport(c.audits)
+}
+
+func (c *Crawler) WriteAuditReport(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(c.AuditReport())
+}
@@ -0,0 +1,143 @@
+package crawlbase
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEvaluateCSP(t *testing.T) {
+	csp := ParseCSP("default-src 'self'; script-src 'self' 'unsafe-inline' 'unsafe-eval' https:; object-src 'none'")
+	details := []string{}
+	for _, f := range EvaluateCSP(csp) {
+		details = append(details, f.Detail)
+	}
+	expected := []string{"script-src allows 'unsafe-inline'", "script-src allows 'unsafe-eval'", "script-src allows https:", "no base-uri"}
+	if len(details) != len(expected) {
+		t.Error("wrong csp findings ", details)
+	}
+	for _, d := range expected {
+		if !ContainsString(details, d) {
+			t.Error("missing ", d, " in ", details)
+		}
+	}
+
+	csp = ParseCSP("script-src 'nonce-abc' 'unsafe-inline'; object-src 'none'; base-uri 'none'")
+	if findings := EvaluateCSP(csp); len(findings) != 0 {
+		t.Error("unsafe-inline with nonce reported ", findings)
+	}
+}
+
+func TestAuditPage(t *testing.T) {
+	page := &Page{URL: "https://test.com/account"}
+	page.Response = &PageResponse{StatusCode: 200, ContentMIME: "text/html", Header: http.Header{}}
+	page.Response.Header.Set("Strict-Transport-Security", "max-age=300")
+	page.Response.Header.Set("X-Frame-Options", "ALLOW-FROM https://a.com")
+	page.Response.Header.Set("Access-Control-Allow-Origin", "*")
+	page.Response.Header.Set("Access-Control-Allow-Credentials", "true")
+	page.Response.Header.Add("Set-Cookie", "session=1; Path=/; HttpOnly")
+	res := http.Response{Header: page.Response.Header}
+	page.Response.Cookies = CookiesFromHTTP(res.Cookies())
+	page.RespInfo.Ressources = []Ressource{
+		{Tag: "script", Url: "http://cdn.test.com/a.js"},
+		{Tag: "img", Url: "http://cdn.test.com/a.png"},
+		{Tag: "css", Type: "url", Url: "http://cdn.test.com/font.woff"},
+		{Tag: "css", Type: "import", Url: "http://cdn.test.com/b.css"},
+		{Tag: "js-endpoint", Type: "string", Url: "http://api.test.com/v1"},
+		{Tag: "js", Url: "http://api.test.com/v2"},
+		{Tag: "sourcemap", Url: "http://cdn.test.com/a.js.map"},
+		{Tag: "meta", Type: "refresh", Url: "http://test.com/next"},
+		{Tag: "link", Rel: "canonical", Url: "http://test.com/account"},
+	}
+
+	found := map[string]string{}
+	for _, f := range AuditPage(page) {
+		found[f.Detail] = f.Severity
+	}
+	expected := map[string]string{
+		"Strict-Transport-Security max-age 300 is short":           SeverityLow,
+		"Content-Security-Policy missing":                          SeverityMedium,
+		"invalid X-Frame-Options ALLOW-FROM HTTPS://A.COM":         SeverityLow,
+		"X-Content-Type-Options nosniff missing":                   SeverityLow,
+		"Referrer-Policy missing":                                  SeverityLow,
+		"wildcard origin with credentials":                         SeverityLow,
+		`authenticated response may be cached: Cache-Control ""`:   SeverityMedium,
+		"session without Secure":                                   SeverityMedium,
+		"session without SameSite":                                 SeverityLow,
+		"active content over http: http://cdn.test.com/a.js":       SeverityHigh,
+		"passive content over http: http://cdn.test.com/a.png":     SeverityLow,
+		"passive content over http: http://cdn.test.com/font.woff": SeverityLow,
+		"active content over http: http://cdn.test.com/b.css":      SeverityHigh,
+	}
+	for detail, severity := range expected {
+		if found[detail] != severity {
+			t.Error("missing ", detail, " ", severity)
+		}
+	}
+	if len(found) != len(expected) {
+		t.Error("wrong findings ", found)
+	}
+
+	other := &Page{URL: "https://test.com/other", Response: &PageResponse{StatusCode: 200, ContentMIME: "text/html", Header: http.Header{}}}
+	report := AuditReportFromPages([]*Page{page, other})
+	if len(report) != 1 || report[0].Findings[0].Severity != SeverityHigh {
+		t.Fatal("wrong report ", report)
+	}
+	for _, f := range report[0].Findings {
+		if f.Detail == "Content-Security-Policy missing" && len(f.Urls) != 2 {
+			t.Error("finding not merged ", f)
+		}
+	}
+}
+
+func TestResponseCookies(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "sid", Value: "secret", HttpOnly: true, SameSite: http.SameSiteLaxMode})
+	}))
+	defer ts.Close()
+
+	cw := NewCrawler()
+	page, err := cw.GetPage(ts.URL, "GET")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookies := page.Response.Cookies
+	if len(cookies) != 1 || cookies[0].Value != "secret" || !cookies[0].Httponly || cookies[0].SameSite != "Lax" {
+		t.Error("wrong stored cookies ", cookies)
+	}
+	if names := GetCookieNames(page.Response); len(names) != 1 || names[0] != "sid" {
+		t.Error("wrong cookie names ", names)
+	}
+}
+
+func TestRedactCookies(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "sid", Value: "secret", HttpOnly: true, SameSite: http.SameSiteLaxMode})
+	}))
+	defer ts.Close()
+
+	cw := NewCrawler()
+	cw.RedactCookies = true
+	cw.Header.Set("Cookie", "session=token; lang=en")
+	page, err := cw.GetPage(ts.URL, "GET")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookies := page.Response.Cookies
+	if len(cookies) != 1 || cookies[0].Name != "sid" || cookies[0].Value != "" || !cookies[0].Httponly {
+		t.Error("wrong stored cookies ", cookies)
+	}
+	if line := page.Response.Header.Get("Set-Cookie"); strings.Contains(line, "secret") || !strings.HasPrefix(line, "sid=;") || !strings.Contains(line, "HttpOnly") {
+		t.Error("set-cookie header not redacted ", line)
+	}
+	if line := page.Request.Header.Get("Cookie"); line != "session=; lang=" {
+		t.Error("cookie header not redacted ", line)
+	}
+	if cw.Header.Get("Cookie") != "session=token; lang=en" {
+		t.Error("crawler header changed ", cw.Header.Get("Cookie"))
+	}
+	if names := GetCookieNames(page.Response); len(names) != 1 || names[0] != "sid" {
+		t.Error("wrong cookie names ", names)
+	}
+}
@@ -0,0 +1,264 @@
+package crawlbase
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+const bodyStoreFolder = "bodies"
+const bodyStoreRefsFile = "refs.json"
+
+// refs are written every bodyStoreSaveEvery pages and when a crawl ends
+const bodyStoreSaveEvery = 100
+
+// BodyStore keeps every distinct response body once, named by its hash
+type BodyStore struct {
+	Folder      string `json:"-"`
+	Compression string `json:"-"`
+	Refs        map[string]int
+	Sizes       map[string]int64
+	// last stored body per url, used to detect revisits
+	Visits     map[string]BodyVisit
+	SavedBytes int64
+	unsaved    int
+}
+
+type BodyVisit struct {
+	Hash      string
+	CrawlTime int
+}
+
+type DedupReport struct {
+	Bodies      int
+	References  int
+	StoredBytes int64
+	SavedBytes  int64
+}
+
+func OpenBodyStore(folder string) (*BodyStore, error) {
+	bs := &BodyStore{
+		Folder: folder,
+		Refs:   map[string]int{},
+		Sizes:  map[string]int64{},
+		Visits: map[string]BodyVisit{},
+	}
+
+	err := os.MkdirAll(folder, 0777)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := ioutil.ReadFile(path.Join(folder, bodyStoreRefsFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return bs, nil
+		}
+		return nil, err
+	}
+
+	err = json.Unmarshal(content, bs)
+	if err != nil {
+		return nil, err
+	}
+	return bs, nil
+}
+
+func (bs *BodyStore) bodyPath(hash string) string {
+	return path.Join(bs.Folder, hash+".respbin")
+}
+
+// Put stores the body if it is not known yet and adds a reference to it
+func (bs *BodyStore) Put(body []byte) (string, error) {
+	hash := ToHash(string(body))
+	if bs.Refs[hash] > 0 {
+		bs.Refs[hash] += 1
+		bs.SavedBytes += int64(len(body))
+		return hash, nil
+	}
+
+	err := writeStorageFile(bs.bodyPath(hash), body, bs.Compression)
+	if err != nil {
+		return "", err
+	}
+	bs.Refs[hash] = 1
+	bs.Sizes[hash] = int64(len(body))
+	return hash, nil
+}
+
+func (bs *BodyStore) Get(hash string) ([]byte, error) {
+	return readStorageFile(bs.bodyPath(hash))
+}
+
+// Release removes a reference and deletes the body when it is unused
+func (bs *BodyStore) Release(hash string) error {
+	refs, ok := bs.Refs[hash]
+	if !ok {
+		return nil
+	}
+	if refs > 1 {
+		bs.Refs[hash] = refs - 1
+		bs.SavedBytes -= bs.Sizes[hash]
+		return nil
+	}
+
+	delete(bs.Refs, hash)
+	delete(bs.Sizes, hash)
+	err := removeStorageFile(bs.bodyPath(hash))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Visit records the body of a page and reports if it equals the last body
+// seen for the same url
+func (bs *BodyStore) Visit(page *Page) (BodyVisit, bool) {
+	prev, hasPrev := bs.Visits[page.URL]
+	bs.Visits[page.URL] = BodyVisit{Hash: page.BodyHash, CrawlTime: page.CrawlTime}
+	if hasPrev && prev.Hash == page.BodyHash {
+		return prev, true
+	}
+	return prev, false
+}
+
+// Save writes the refs to a temporary file and renames it, a crash while
+// saving keeps the previous refs
+func (bs *BodyStore) Save() error {
+	content, err := json.Marshal(bs)
+	if err != nil {
+		return err
+	}
+	refsFile := path.Join(bs.Folder, bodyStoreRefsFile)
+	err = ioutil.WriteFile(refsFile+".tmp", content, 0666)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(refsFile+".tmp", refsFile)
+	if err != nil {
+		return err
+	}
+	bs.unsaved = 0
+	return nil
+}
+
+// saveEvery saves the refs after every n changes
+func (bs *BodyStore) saveEvery(n int) error {
+	bs.unsaved += 1
+	if bs.unsaved < n {
+		return nil
+	}
+	return bs.Save()
+}
+
+func (bs *BodyStore) Report() DedupReport {
+	report := DedupReport{SavedBytes: bs.SavedBytes}
+	for hash, refs := range bs.Refs {
+		report.Bodies += 1
+		report.References += refs
+		report.StoredBytes += bs.Sizes[hash]
+	}
+	return report
+}
+
+func (c *Crawler) BodyStore() (*BodyStore, error) {
+	if c.bodyStore != nil {
+		return c.bodyStore, nil
+	}
+	bs, err := OpenBodyStore(path.Join(c.StorageFolder, bodyStoreFolder))
+	if err != nil {
+		return nil, err
+	}
+	bs.Compression = c.Compression
+	c.bodyStore = bs
+	return bs, nil
+}
+
+func (c *Crawler) saveBodyDedup(page *Page) error {
+	bs, err := c.BodyStore()
+	if err != nil {
+		return err
+	}
+
+	prev, unchanged := bs.Visit(page)
+	if unchanged {
+		page.Revisit = true
+		page.RevisitOf = prev.CrawlTime
+	}
+
+	if page.Unchanged && bs.Refs[page.BodyHash] > 0 {
+		// 304 without body, reference the body of the previous crawl
+		bs.Refs[page.BodyHash] += 1
+		bs.SavedBytes += bs.Sizes[page.BodyHash]
+		return bs.saveEvery(bodyStoreSaveEvery)
+	}
+
+	_, err = bs.Put(page.ResponseBody)
+	if err != nil {
+		return err
+	}
+	return bs.saveEvery(bodyStoreSaveEvery)
+}
+
+// SaveBodyStore writes the refs of deduplicated bodies, FetchSites calls it
+// when the crawl ends
+func (c *Crawler) SaveBodyStore() error {
+	if c.bodyStore == nil || c.bodyStore.unsaved == 0 {
+		return nil
+	}
+	return c.bodyStore.Save()
+}
+
+// RemovePage deletes a stored page and releases its deduplicated body
+func (c *Crawler) RemovePage(filepath string) error {
+	filepath = trimCompressionSuffix(filepath)
+	page, err := LoadPage(filepath, false)
+	if err != nil {
+		return err
+	}
+
+	respbinfile := strings.Replace(filepath, ".httpi", ".respbin", 1)
+	err = removeStorageFile(respbinfile)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	reqbinfile := strings.Replace(filepath, ".httpi", ".reqbin", 1)
+	err = removeStorageFile(reqbinfile)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if c.DedupBodies && page.BodyHash != "" {
+		bs, err := c.BodyStore()
+		if err != nil {
+			return err
+		}
+		err = bs.Release(page.BodyHash)
+		if err != nil {
+			return err
+		}
+		err = bs.Save()
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.Index != nil {
+		err = c.Index.Remove(filepath)
+		if err != nil {
+			return err
+		}
+	}
+
+	return removeStorageFile(filepath)
+}
+
+func (c *Crawler) DedupReport() (DedupReport, error) {
+	bs, err := c.BodyStore()
+	if err != nil {
+		return DedupReport{}, err
+	}
+	return bs.Report(), nil
+}
@@ -0,0 +1,95 @@
+package crawlbase
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestBodyStoreRefs(t *testing.T) {
+	folder, err := ioutil.TempDir("", "bodystore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+
+	bs, err := OpenBodyStore(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := []byte("not found")
+	hash, _ := bs.Put(body)
+	bs.Put(body)
+
+	report := bs.Report()
+	if report.Bodies != 1 || report.References != 2 || report.SavedBytes != int64(len(body)) {
+		t.Error("incorrect report: ", report)
+	}
+
+	bs.Release(hash)
+	if _, err := bs.Get(hash); err != nil {
+		t.Error("body removed while still referenced")
+	}
+	bs.Release(hash)
+	if _, err := bs.Get(hash); err == nil {
+		t.Error("unreferenced body not removed")
+	}
+}
+
+func TestSavePageDedup(t *testing.T) {
+	folder, err := ioutil.TempDir("", "storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+
+	cw := NewCrawler()
+	cw.StorageFolder = folder
+	cw.DedupBodies = true
+
+	p := PageFromData([]byte("<html></html>"), nil, false)
+	p.URL = "http://test.com"
+	p.CrawlTime = 1
+	cw.SavePage(p)
+
+	p2 := PageFromData([]byte("<html></html>"), nil, false)
+	p2.URL = "http://test.com"
+	p2.CrawlTime = 2
+	cw.SavePage(p2)
+	if !p2.Revisit || p2.RevisitOf != 1 {
+		t.Error("unchanged body not recorded as revisit")
+	}
+
+	loaded, err := LoadPage(path.Join(folder, "2.httpi"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(loaded.ResponseBody) != "<html></html>" {
+		t.Error("deduplicated body not loaded")
+	}
+
+	report, _ := cw.DedupReport()
+	if report.Bodies != 1 || report.References != 2 {
+		t.Error("incorrect report: ", report)
+	}
+
+	refsFile := path.Join(folder, bodyStoreFolder, bodyStoreRefsFile)
+	if _, err := os.Stat(refsFile); err == nil {
+		t.Error("refs saved for every page")
+	}
+	err = cw.SaveBodyStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(refsFile + ".tmp"); err == nil {
+		t.Error("temporary refs file not renamed")
+	}
+	bs, err := OpenBodyStore(path.Join(folder, bodyStoreFolder))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report := bs.Report(); report.Bodies != 1 || report.References != 2 {
+		t.Error("refs not saved: ", report)
+	}
+}
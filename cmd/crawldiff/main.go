@@ -0,0 +1,38 @@
+// crawldiff compares two storage folders of the same site
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/mpfund/crawlbase"
+)
+
+func main() {
+	asJson := flag.Bool("json", false, "print the diff as json")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: crawldiff [-json] oldfolder newfolder")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	diff, err := crawlbase.DiffCrawls(flag.Arg(0), flag.Arg(1))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *asJson {
+		err = diff.WriteJSON(os.Stdout)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	fmt.Print(diff.Summary())
+}
@@ -0,0 +1,52 @@
+// crawlindex rebuilds and queries the page index of a storage folder
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/mpfund/crawlbase"
+)
+
+func main() {
+	storage := flag.String("storage", "./storage", "storage folder")
+	reindex := flag.Bool("reindex", false, "rebuild the index from the stored pages")
+	q := crawlbase.PageQuery{}
+	flag.StringVar(&q.Host, "host", "", "host")
+	flag.IntVar(&q.StatusCode, "status", 0, "status code")
+	flag.StringVar(&q.ContentMIME, "mime", "", "content mime type")
+	flag.StringVar(&q.Header, "header", "", "response header name")
+	flag.StringVar(&q.HeaderValue, "headervalue", "", "part of the response header value")
+	flag.StringVar(&q.CookieName, "cookie", "", "cookie name")
+	flag.StringVar(&q.FormAction, "form", "", "part of a form action url")
+	flag.StringVar(&q.RessourceUrl, "res", "", "part of a ressource url")
+	flag.StringVar(&q.BodyText, "text", "", "text in the response body, reads the stored bodies from disk")
+	flag.Parse()
+
+	if *reindex {
+		count, err := crawlbase.ReindexFolder(*storage)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Println("indexed", count, "page(s)")
+	}
+
+	index, err := crawlbase.OpenFolderIndex(*storage)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer index.Close()
+
+	if *reindex && q == (crawlbase.PageQuery{}) {
+		return
+	}
+
+	entries, err := index.Find(q)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, entry := range entries {
+		fmt.Println(entry.StatusCode, entry.URL, entry.File)
+	}
+}
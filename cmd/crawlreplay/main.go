@@ -0,0 +1,24 @@
+// crawlreplay serves a storage folder over http
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/mpfund/crawlbase"
+)
+
+func main() {
+	storage := flag.String("storage", "./storage", "storage folder")
+	addr := flag.String("addr", "127.0.0.1:8080", "listen address")
+	flag.Parse()
+
+	rs, err := crawlbase.NewReplayServer(*storage)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Println("replaying", *storage, "on", *addr)
+	log.Fatal(http.ListenAndServe(*addr, rs))
+}
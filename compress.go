@@ -0,0 +1,127 @@
+package crawlbase
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	CompressionNone = ""
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+var ErrorUnknownCompression = errors.New("unknown compression")
+
+// compressed storage files carry the suffix of their compression, files
+// without suffix are read unchanged
+var compressionSuffixes = map[string]string{
+	CompressionGzip: ".gz",
+	CompressionZstd: ".zst",
+}
+
+func Compress(data []byte, compression string) ([]byte, error) {
+	switch compression {
+	case CompressionNone:
+		return data, nil
+	case CompressionGzip:
+		buf := &bytes.Buffer{}
+		w := gzip.NewWriter(buf)
+		_, err := w.Write(data)
+		if err != nil {
+			return nil, err
+		}
+		err = w.Close()
+		if err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case CompressionZstd:
+		enc, err := zstd.NewWriter(nil)
+		if err != nil {
+			return nil, err
+		}
+		defer enc.Close()
+		return enc.EncodeAll(data, nil), nil
+	}
+	return nil, ErrorUnknownCompression
+}
+
+func Decompress(data []byte, compression string) ([]byte, error) {
+	switch compression {
+	case CompressionNone:
+		return data, nil
+	case CompressionGzip:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return ioutil.ReadAll(r)
+	case CompressionZstd:
+		dec, err := zstd.NewReader(nil)
+		if err != nil {
+			return nil, err
+		}
+		defer dec.Close()
+		return dec.DecodeAll(data, nil)
+	}
+	return nil, ErrorUnknownCompression
+}
+
+// trimCompressionSuffix returns the name of a storage file without its
+// compression suffix
+func trimCompressionSuffix(name string) string {
+	for _, suffix := range compressionSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return strings.TrimSuffix(name, suffix)
+		}
+	}
+	return name
+}
+
+// storedFile returns the file on disk and its compression for the name of a
+// storage file, the name may already have a compression suffix
+func storedFile(filePath string) (string, string) {
+	filePath = trimCompressionSuffix(filePath)
+	for compression, suffix := range compressionSuffixes {
+		if _, err := os.Stat(filePath + suffix); err == nil {
+			return filePath + suffix, compression
+		}
+	}
+	return filePath, CompressionNone
+}
+
+func writeStorageFile(filePath string, data []byte, compression string) error {
+	filePath = trimCompressionSuffix(filePath)
+	content, err := Compress(data, compression)
+	if err != nil {
+		return err
+	}
+	// drop a version of the file stored with another compression
+	err = removeStorageFile(filePath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return ioutil.WriteFile(filePath+compressionSuffixes[compression], content, 0666)
+}
+
+func readStorageFile(filePath string) ([]byte, error) {
+	name, compression := storedFile(filePath)
+	content, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return Decompress(content, compression)
+}
+
+func removeStorageFile(filePath string) error {
+	name, _ := storedFile(filePath)
+	return os.Remove(name)
+}
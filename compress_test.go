@@ -0,0 +1,102 @@
+package crawlbase
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestCompressRoundTrip(t *testing.T) {
+	data := []byte("<html><body>compress me</body></html>")
+	for _, compression := range []string{CompressionNone, CompressionGzip, CompressionZstd} {
+		compressed, err := Compress(data, compression)
+		if err != nil {
+			t.Fatal(err)
+		}
+		plain, err := Decompress(compressed, compression)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(plain, data) {
+			t.Error("round trip failed for ", compression)
+		}
+	}
+}
+
+func TestSavePageCompressed(t *testing.T) {
+	folder, err := ioutil.TempDir("", "storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+
+	cw := NewCrawler()
+	cw.StorageFolder = folder
+	cw.Compression = CompressionZstd
+
+	p := PageFromData([]byte("<a href='http://test.com/1'></a>"), nil, false)
+	p.URL = "http://test.com"
+	p.CrawlTime = 1
+	p.RequestBody = []byte("q=1")
+	cw.SavePage(p)
+
+	if _, err := os.Stat(path.Join(folder, "1.respbin.zst")); err != nil {
+		t.Error("compressed body not marked ", err)
+	}
+	files, _ := GetPageInfoFiles(folder)
+	if len(files) != 1 || files[0] != path.Join(folder, "1.httpi") {
+		t.Error("wrong page files ", files)
+	}
+	loaded, err := LoadPage(path.Join(folder, "1.httpi"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.URL != p.URL || !bytes.Equal(loaded.ResponseBody, p.ResponseBody) {
+		t.Error("compressed page not loaded")
+	}
+
+	// the name on disk works as well
+	loaded, err = LoadPage(path.Join(folder, "1.httpi.zst"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(loaded.ResponseBody, p.ResponseBody) || string(loaded.RequestBody) != "q=1" {
+		t.Error("page not loaded by its name on disk")
+	}
+	err = cw.RemovePage(path.Join(folder, "1.httpi.zst"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := ioutil.ReadDir(folder); len(entries) != 0 {
+		t.Error("stored files not removed ", entries)
+	}
+}
+
+func TestSavePageRawGzipBody(t *testing.T) {
+	folder, err := ioutil.TempDir("", "storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+
+	cw := NewCrawler()
+	cw.StorageFolder = folder
+
+	// a .gz download stays compressed when compression is off
+	body, err := Compress([]byte("archive content"), CompressionGzip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Page{URL: "http://test.com/archive.gz", CrawlTime: 1, ResponseBody: body}
+	cw.SavePage(p)
+
+	loaded, err := LoadPage(path.Join(folder, "1.httpi"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(loaded.ResponseBody, body) {
+		t.Error("gzip body changed on load")
+	}
+}
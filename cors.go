@@ -0,0 +1,166 @@
+package crawlbase
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// origin of the attacker in cors probes, .example is reserved and never
+// resolves
+const DefaultCORSAttacker = "crawlbase-attacker.example"
+
+// CORSProbe is an Origin header sent to test what a server trusts
+type CORSProbe struct {
+	Name   string
+	Origin string
+}
+
+type CORSFinding struct {
+	Url         string
+	Probe       string
+	Origin      string
+	AllowOrigin string
+	Credentials bool
+	Severity    string
+}
+
+// CORSProbes returns origins for an attacker domain, null, the target
+// host as prefix or suffix of an attacker domain, a subdomain and the
+// insecure scheme
+func CORSProbes(target *url.URL, attacker string) []CORSProbe {
+	host := target.Hostname()
+	scheme := target.Scheme
+	probes := []CORSProbe{
+		{"attacker", scheme + "://" + attacker},
+		{"null", "null"},
+		{"prefix", scheme + "://" + host + "." + attacker},                     // matches origin.startsWith(host)
+		{"suffix", scheme + "://" + "attacker" + host},                         // matches origin.endsWith(host)
+		{"subdomain", scheme + "://" + "attacker." + host},                     // trusted subdomains can be taken over
+		{"unescaped-dot", scheme + "://" + strings.Replace(host, ".", "x", 1)}, // regex with an unescaped dot
+	}
+	if scheme == "https" {
+		probes = append(probes, CORSProbe{"http", "http://" + target.Host})
+	}
+	return probes
+}
+
+// CORSSeverity rates the response to a probe, an empty result is no finding
+func CORSSeverity(probe CORSProbe, allowOrigin string, credentials bool) string {
+	allowOrigin = strings.TrimSpace(allowOrigin)
+	switch {
+	case allowOrigin == "*" && credentials:
+		// browsers refuse credentials for a wildcard origin
+		return SeverityLow
+	case allowOrigin == "*":
+		return SeverityInfo
+	case allowOrigin == "" || allowOrigin != probe.Origin:
+		return ""
+	case probe.Name == "subdomain" || probe.Name == "http":
+		if credentials {
+			return SeverityMedium
+		}
+		return SeverityLow
+	case credentials:
+		return SeverityHigh
+	default:
+		return SeverityMedium
+	}
+}
+
+// IsCORSCandidate selects pages worth probing, api responses, pages which
+// already send cors headers and authenticated pages
+func IsCORSCandidate(page *Page) bool {
+	if page.Response == nil {
+		return false
+	}
+	if page.Request != nil && page.Request.Method != "" && page.Request.Method != "GET" && page.Request.Method != "HEAD" {
+		return false
+	}
+	return page.Response.Header.Get("Access-Control-Allow-Origin") != "" ||
+		strings.Contains(page.Response.ContentMIME, "json") ||
+		strings.Contains(page.Response.ContentMIME, "xml") ||
+		isAuthenticated(page)
+}
+
+// CheckCORS replays the request of a page with each probe as Origin. The
+// stored request headers are sent again, so authenticated pages are probed
+// with their cookies.
+func (c *Crawler) CheckCORS(page *Page) ([]CORSFinding, error) {
+	findings := []CORSFinding{}
+	target, err := url.Parse(page.URL)
+	if err != nil {
+		return findings, err
+	}
+	method := "GET"
+	if page.Request != nil && page.Request.Method == "HEAD" {
+		method = "HEAD"
+	}
+
+	for _, probe := range CORSProbes(target, DefaultCORSAttacker) {
+		req, err := http.NewRequest(method, page.URL, nil)
+		if err != nil {
+			return findings, err
+		}
+		if page.Request != nil {
+			for k, v := range page.Request.Header {
+				req.Header[k] = v
+			}
+		}
+		req.Header.Set("Origin", probe.Origin)
+
+		probePage, err := c.DoRequest(req)
+		if err != nil {
+			log.Println("CheckCORS ", err)
+			continue
+		}
+		allowOrigin := probePage.Response.Header.Get("Access-Control-Allow-Origin")
+		credentials := strings.EqualFold(strings.TrimSpace(probePage.Response.Header.Get("Access-Control-Allow-Credentials")), "true")
+		severity := CORSSeverity(probe, allowOrigin, credentials)
+		if severity == "" {
+			continue
+		}
+		findings = append(findings, CORSFinding{
+			Url:         page.URL,
+			Probe:       probe.Name,
+			Origin:      probe.Origin,
+			AllowOrigin: allowOrigin,
+			Credentials: credentials,
+			Severity:    severity,
+		})
+		if allowOrigin == "*" {
+			// the answer does not depend on the origin
+			break
+		}
+	}
+	return findings, nil
+}
+
+// CheckCORSPages probes the pages accepted by filter, IsCORSCandidate if
+// filter is nil
+func (c *Crawler) CheckCORSPages(pages []*Page, filter func(*Page) bool) []CORSFinding {
+	if filter == nil {
+		filter = IsCORSCandidate
+	}
+	findings := []CORSFinding{}
+	for _, page := range pages {
+		if !filter(page) {
+			continue
+		}
+		found, err := c.CheckCORS(page)
+		if err != nil {
+			log.Println("CheckCORSPages ", err)
+		}
+		findings = append(findings, found...)
+	}
+	return findings
+}
+
+func WriteCORSReport(w io.Writer, findings []CORSFinding) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(findings)
+}
@@ -0,0 +1,58 @@
+package crawlbase
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCheckCORS(t *testing.T) {
+	// trusts every origin ending with the host, a common mistake
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin != "" && r.Header.Get("Cookie") == "" {
+			t.Error("stored cookie not replayed")
+		}
+		if strings.HasSuffix(origin, "127.0.0.1") {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("{}"))
+	}))
+	defer ts.Close()
+
+	c := NewCrawler()
+	c.StorageFolder = ""
+	page, err := c.GetPage(ts.URL+"/api/me", "GET")
+	if err != nil {
+		t.Fatal(err)
+	}
+	page.Request.Header.Set("Cookie", "session=1")
+	if !IsCORSCandidate(page) {
+		t.Error("json page not selected")
+	}
+
+	findings := c.CheckCORSPages([]*Page{page}, nil)
+	probes := map[string]string{}
+	for _, f := range findings {
+		probes[f.Probe] = f.Severity
+	}
+	if len(findings) != 2 || probes["suffix"] != SeverityHigh || probes["subdomain"] != SeverityMedium {
+		t.Error("wrong findings ", findings)
+	}
+}
+
+func TestCORSSeverity(t *testing.T) {
+	probe := CORSProbe{"attacker", "https://" + DefaultCORSAttacker}
+	if CORSSeverity(probe, "https://test.com", true) != "" {
+		t.Error("fixed origin reported")
+	}
+	if CORSSeverity(probe, probe.Origin, false) != SeverityMedium || CORSSeverity(probe, "*", true) != SeverityLow {
+		t.Error("wrong severity")
+	}
+	if CORSSeverity(probe, probe.Origin, true) != SeverityHigh {
+		t.Error("reflected origin with credentials not high")
+	}
+}
@@ -33,8 +33,16 @@ type Page struct {
 	Request      *PageRequest
 	RespInfo     ResponseInfo
 	Error        string
-	ResponseBody []byte `json:"-"`
-	RequestBody  []byte `json:"-"`
+	BodyHash     string
+	Revisit      bool            `json:",omitempty"`
+	Unchanged    bool            `json:",omitempty"` // not modified since RevisitOf
+	RevisitOf    int             `json:",omitempty"` // crawl time of the page with the same body
+	Secrets      []SecretFinding `json:",omitempty"`
+	Leaks        []Leak          `json:",omitempty"`
+	Technologies []Technology    `json:",omitempty"`
+	Audit        []AuditFinding  `json:",omitempty"`
+	ResponseBody []byte          `json:"-"`
+	RequestBody  []byte          `json:"-"`
 }
 
 type PageResponse struct {
@@ -48,29 +56,54 @@ type PageResponse struct {
 
 type PageRequest struct {
 	Header        http.Header
+	Method        string
 	Proto         string
 	ContentLength int64
 	Cookies       []Cookie
 }
 
 type ResponseInfo struct {
-	Hrefs      []string
-	Forms      []Form
-	Ressources []Ressource
-	JSInfo     []JSInfo
-	Requests   []Ressource
+	Hrefs       []string
+	Forms       []Form
+	Ressources  []Ressource
+	JSInfo      []JSInfo
+	Requests    []Ressource
+	MetaRefresh string        `json:",omitempty"`
+	Meta        *DocumentMeta `json:",omitempty"`
+	Links       []Link        `json:",omitempty"`
 }
 
 type FormInput struct {
-	Name  string
-	Type  string
-	Value string
+	Name      string
+	Type      string
+	Value     string
+	Tag       string       // input, select, textarea or button
+	Required  bool         `json:",omitempty"`
+	Pattern   string       `json:",omitempty"`
+	MaxLength int          `json:",omitempty"`
+	Checked   bool         `json:",omitempty"`
+	Multiple  bool         `json:",omitempty"`
+	Options   []FormOption `json:",omitempty"`
+	// overrides of submit buttons
+	FormAction  string `json:",omitempty"`
+	FormMethod  string `json:",omitempty"`
+	FormEnctype string `json:",omitempty"`
+}
+
+type FormOption struct {
+	Value    string
+	Text     string
+	Selected bool
 }
 
 type Form struct {
-	Url    string
-	Method string
-	Inputs []FormInput
+	Url           string
+	Method        string
+	Enctype       string `json:",omitempty"`
+	AcceptCharset string `json:",omitempty"`
+	Id            string `json:",omitempty"`
+	Name          string `json:",omitempty"`
+	Inputs        []FormInput
 }
 
 type Cookie struct {
@@ -78,13 +111,17 @@ type Cookie struct {
 	Value    string
 	Domain   string
 	Httponly bool
+	Path     string `json:",omitempty"`
+	Secure   bool   `json:",omitempty"`
+	SameSite string `json:",omitempty"` // Lax, Strict or None
 }
 
 type Ressource struct {
-	Url  string
-	Type string
-	Rel  string
-	Tag  string
+	Url    string
+	Type   string
+	Rel    string
+	Tag    string
+	Source string `json:",omitempty"` // where the url was found
 }
 
 type JSInfo struct {
@@ -104,6 +141,40 @@ type Crawler struct {
 	PageCount           uint64
 	StorageFolder       string
 	ScopeToDomain       bool
+	DedupBodies         bool
+	Compression         string // CompressionNone, CompressionGzip or CompressionZstd, files get a .gz or .zst suffix
+	Index               *PageIndex
+	Previous            map[string]*Page // pages of the previous crawl by url
+	previousFolder      string
+	changes             ChangeReport
+	SubmitForms         bool
+	FormFiller          *FormFiller
+	submittedForms      map[string]bool
+	FetchScripts        bool
+	FetchSourceMaps     bool // fetches the scripts of pages like FetchScripts
+	FetchStylesheets    bool
+	stylesheets         map[string]*Stylesheet
+	scriptEndpoints     map[string][]Ressource
+	ExecuteJS           bool
+	JSSandbox           *JSSandbox
+	scriptBodies        map[string][]byte // external scripts run by ExecuteJS
+	bodyStore           *BodyStore
+	RespectCanonical    bool     // only follow the canonical url of a page
+	RespectMetaRobots   bool     // honour noindex and nofollow
+	SkipRels            []string // dont follow links with these rel values, eg nofollow
+	ScanSecrets         bool     // scan pages, fetched scripts and source maps
+	SecretScanner       *SecretScanner
+	secrets             []SecretFinding
+	ScanLeaks           bool // emails, phone numbers, internal hosts, comments and more
+	leaks               map[string]*SiteLeaks
+	Fingerprint         bool // detect servers, frameworks and libraries
+	FetchFavicons       bool // fetch the favicon of each host for fingerprinting
+	Fingerprinter       *Fingerprinter
+	faviconHashes       map[string]string
+	technologies        map[string][]Technology
+	Audit               bool // passive check of security headers, cookies and mixed content
+	RedactCookies       bool // dont store cookie values, also in the Set-Cookie and Cookie headers
+	audits              map[string]*HostAudit
 }
 
 type DNSScanner struct {
@@ -112,6 +183,7 @@ type DNSScanner struct {
 
 var headerUserAgentChrome string = "Mozilla/5.0 (Windows NT 6.3; WOW64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/47.0.2526.106 Safari/537.36"
 var ErrorCheckRedirect = errors.New("dont redirect")
+var ErrorOutOfScope = errors.New("url out of scope")
 
 func NewCrawler() *Crawler {
 	cw := Crawler{}
@@ -133,15 +205,24 @@ func NewCrawler() *Crawler {
 }
 
 func (c *Crawler) GetPage(crawlUrl, method string) (*Page, error) {
-	timeStart := time.Now()
 	req, err := http.NewRequest(method, crawlUrl, nil)
 	if err != nil {
 		log.Println("GetPage ", err)
 		return nil, err
 	}
 
+	return c.DoRequest(req)
+}
+
+// DoRequest sends req with the crawler headers, headers already set on req
+// are kept
+func (c *Crawler) DoRequest(req *http.Request) (*Page, error) {
+	timeStart := time.Now()
+
 	for k, v := range c.Header {
-		req.Header.Set(k, v[0])
+		if _, isSet := req.Header[k]; !isSet {
+			req.Header.Set(k, v[0])
+		}
 	}
 
 	res, err := c.Client.Do(req)
@@ -187,12 +268,15 @@ func (cw *Crawler) FetchSites(startUrl *url.URL) error {
 
 		if !found {
 			log.Println("no more links. crawled ", cw.PageCount, "page(s).")
-			return nil // done
+			return cw.SaveBodyStore() // done
 		}
 
 		if cw.BeforeCrawlFn != nil {
 			url, err := cw.BeforeCrawlFn(urlStr)
 			if err != nil {
+				if serr := cw.SaveBodyStore(); serr != nil {
+					log.Println("FetchSites ", serr)
+				}
 				return err
 			}
 			urlStr = url
@@ -210,31 +294,80 @@ func (cw *Crawler) FetchSites(startUrl *url.URL) error {
 			continue
 		}
 
-		page, err := cw.GetPage(urlStr, "GET")
+		page, err := cw.fetchPage(urlStr)
 		log.Println("fetched site: "+urlStr, page.Response.StatusCode, len(page.ResponseBody))
 
-		userLinks := page.RespInfo.Hrefs
-		if cw.AfterCrawlFn != nil {
-			userLinks, err = cw.AfterCrawlFn(page, err)
+		if cw.FetchScripts || cw.FetchSourceMaps {
+			cw.FetchScriptEndpoints(page)
 		}
-
-		if err != nil {
-			log.Println("after page crawl error: ", err)
+		if cw.FetchStylesheets {
+			cw.FetchStylesheetRessources(page)
+			cw.ApplyStylesheetVisibility(page)
+		}
+		if cw.ExecuteJS {
+			cw.ExecutePageScripts(page)
+		}
+		if cw.ScanSecrets {
+			cw.ScanPageSecrets(page)
+		}
+		if cw.ScanLeaks {
+			cw.ScanPageLeaks(page)
+		}
+		if cw.Fingerprint {
+			cw.FingerprintPage(page)
+		}
+		if cw.Audit {
+			cw.AuditPage(page)
 		}
 
-		cw.SavePage(page)
-		cw.PageCount += 1
+		cw.processPage(page, err, startUrl)
 
-		if startUrl !=nil && cw.ScopeToDomain {
-			cw.AddLinksMatchingDomain(userLinks, startUrl)
-		} else {
-			cw.AddAllLinks(userLinks)
+		if cw.SubmitForms {
+			cw.submitPageForms(page, startUrl)
 		}
 
 		time.Sleep(time.Duration(cw.WaitBetweenRequests) * time.Millisecond)
 	}
 }
 
+// processPage saves a fetched page and adds its links to the frontier
+func (cw *Crawler) processPage(page *Page, err error, startUrl *url.URL) {
+	userLinks := LinksFromPage(page)
+	if cw.AfterCrawlFn != nil {
+		userLinks, err = cw.AfterCrawlFn(page, err)
+	}
+
+	if err != nil {
+		log.Println("after page crawl error: ", err)
+	}
+
+	noindex, nofollow := false, false
+	if cw.RespectMetaRobots {
+		noindex, nofollow = RobotsFromPage(page)
+	}
+	if len(cw.SkipRels) > 0 {
+		userLinks = FilterLinksByRel(page, userLinks, cw.SkipRels)
+	}
+	if nofollow {
+		userLinks = []string{}
+	} else if cw.RespectCanonical {
+		if isCanonical, canonical := CanonicalFromPage(page); isCanonical {
+			userLinks = []string{canonical}
+		}
+	}
+
+	if !noindex {
+		cw.SavePage(page)
+	}
+	cw.PageCount += 1
+
+	if startUrl != nil && cw.ScopeToDomain {
+		cw.AddLinksMatchingDomain(userLinks, startUrl)
+	} else {
+		cw.AddAllLinks(userLinks)
+	}
+}
+
 func (cw *Crawler) IsCrawled(url string) bool {
 	val, hasLink := cw.Links[url]
 	if hasLink && val == true {
@@ -272,10 +405,35 @@ func (cw *Crawler) IsValidScheme(url *url.URL) bool {
 	return ContainsString(cw.ValidSchemes, url.Scheme)
 }
 
+// CheckScope applies BeforeCrawlFn, the valid schemes and ScopeToDomain to
+// an url which is requested outside of the link frontier, like a form
+// action, and returns the url to request
+func (cw *Crawler) CheckScope(rawUrl string, startUrl *url.URL) (*url.URL, error) {
+	if cw.BeforeCrawlFn != nil {
+		u, err := cw.BeforeCrawlFn(rawUrl)
+		if err != nil {
+			return nil, err
+		}
+		rawUrl = u
+	}
+	scopeUrl, err := url.Parse(rawUrl)
+	if err != nil {
+		return nil, err
+	}
+	if !cw.IsValidScheme(scopeUrl) {
+		return nil, ErrorOutOfScope
+	}
+	if startUrl != nil && cw.ScopeToDomain && !IsSameDomain(startUrl, scopeUrl) {
+		return nil, ErrorOutOfScope
+	}
+	return scopeUrl, nil
+}
+
 func PageFromData(data []byte, url *url.URL, includeHiddenLinks bool) *Page {
 	page := Page{}
 
 	page.ResponseBody = data
+	page.BodyHash = ToHash(string(data))
 
 	ioreader := bytes.NewReader(data)
 	doc, err := goquery.NewDocumentFromReader(ioreader)
@@ -284,10 +442,16 @@ func PageFromData(data []byte, url *url.URL, includeHiddenLinks bool) *Page {
 	}
 
 	if err == nil {
-		hrefs := GetHrefs(doc, url, !includeHiddenLinks)
+		baseUrl := GetBaseUrl(doc, url)
+		hrefs := GetHrefs(doc, baseUrl, !includeHiddenLinks)
 		page.RespInfo.Hrefs = hrefs
-		page.RespInfo.Forms = GetFormUrls(doc, url)
-		page.RespInfo.Ressources = GetRessources(doc, url)
+		page.RespInfo.Forms = GetFormUrls(doc, baseUrl)
+		page.RespInfo.Ressources = GetRessources(doc, baseUrl)
+		page.RespInfo.JSInfo = GetJSInfo(doc)
+		page.RespInfo.Requests = GetJSInfoRequests(page.RespInfo.JSInfo, baseUrl)
+		page.RespInfo.MetaRefresh = GetMetaRefresh(doc, baseUrl)
+		page.RespInfo.Meta = GetDocumentMeta(doc, baseUrl)
+		page.RespInfo.Links = GetLinks(doc, baseUrl)
 	}
 
 	page.Response = &PageResponse{}
@@ -306,23 +470,19 @@ func (c *Crawler) PageFromResponse(req *http.Request, res *http.Response, timeDu
 	var err error = nil
 
 	if res != nil {
+		contentMIME := GetContentMime(res.Header)
 		body, err = ioutil.ReadAll(res.Body)
 		if err == nil {
-			page = PageFromData(body, req.URL, c.IncludeHiddenLinks)
+			page = PageFromDataMime(body, req.URL, contentMIME, c.IncludeHiddenLinks)
 		}
 
-		page.Response.ContentMIME = GetContentMime(res.Header)
+		page.Response.ContentMIME = contentMIME
+		page.Response.Cookies = CookiesFromHTTP(res.Cookies())
 		page.Response.StatusCode = res.StatusCode
 		page.Response.Header = res.Header
 		page.Response.Proto = res.Proto
 
-		isRedirect, location := LocationFromPage(page, req.URL)
-		if isRedirect {
-			hasLocation := ContainsString(page.RespInfo.Hrefs, location)
-			if !hasLocation {
-				page.RespInfo.Hrefs = append(page.RespInfo.Hrefs, location)
-			}
-		}
+		AddRedirectHrefs(page, req.URL)
 	}
 
 	page.CrawlTime = int(time.Now().Unix())
@@ -330,12 +490,95 @@ func (c *Crawler) PageFromResponse(req *http.Request, res *http.Response, timeDu
 	page.Uid = ToHash(page.URL)
 	page.RespDuration = int(timeDur.Seconds() * 1000)
 	page.Request.Header = req.Header
+	page.Request.Method = req.Method
 	page.Request.Proto = req.Proto
 	page.Request.ContentLength = req.ContentLength
+	if c.RedactCookies {
+		RedactPageCookies(page)
+	}
 
 	return page
 }
 
+// RedactPageCookies blanks cookie values in the stored cookies and in the
+// Set-Cookie and Cookie headers, the headers are copied first
+func RedactPageCookies(page *Page) {
+	for i := range page.Response.Cookies {
+		page.Response.Cookies[i].Value = ""
+	}
+	if lines, ok := page.Response.Header["Set-Cookie"]; ok {
+		page.Response.Header = page.Response.Header.Clone()
+		redacted := []string{}
+		for _, line := range lines {
+			redacted = append(redacted, redactSetCookie(line))
+		}
+		page.Response.Header["Set-Cookie"] = redacted
+	}
+	if lines, ok := page.Request.Header["Cookie"]; ok {
+		page.Request.Header = page.Request.Header.Clone()
+		redacted := []string{}
+		for _, line := range lines {
+			redacted = append(redacted, redactCookieHeader(line))
+		}
+		page.Request.Header["Cookie"] = redacted
+	}
+}
+
+// redactSetCookie keeps the name and attributes of a Set-Cookie line
+func redactSetCookie(line string) string {
+	pair, attrs := line, ""
+	if i := strings.Index(line, ";"); i >= 0 {
+		pair, attrs = line[:i], line[i:]
+	}
+	return redactCookiePair(pair) + attrs
+}
+
+// redactCookieHeader keeps the names of a Cookie header
+func redactCookieHeader(line string) string {
+	pairs := strings.Split(line, ";")
+	for i, pair := range pairs {
+		pairs[i] = redactCookiePair(pair)
+	}
+	return strings.Join(pairs, ";")
+}
+
+func redactCookiePair(pair string) string {
+	if i := strings.Index(pair, "="); i >= 0 {
+		return pair[:i+1]
+	}
+	return pair
+}
+
+func CookiesFromHTTP(httpCookies []*http.Cookie) []Cookie {
+	cookies := []Cookie{}
+	for _, hc := range httpCookies {
+		c := Cookie{Name: hc.Name, Value: hc.Value, Domain: hc.Domain, Httponly: hc.HttpOnly, Path: hc.Path, Secure: hc.Secure}
+		switch hc.SameSite {
+		case http.SameSiteLaxMode:
+			c.SameSite = "Lax"
+		case http.SameSiteStrictMode:
+			c.SameSite = "Strict"
+		case http.SameSiteNoneMode:
+			c.SameSite = "None"
+		}
+		cookies = append(cookies, c)
+	}
+	return cookies
+}
+
+func (c Cookie) HTTPCookie() *http.Cookie {
+	hc := &http.Cookie{Name: c.Name, Value: c.Value, Domain: c.Domain, HttpOnly: c.Httponly, Path: c.Path, Secure: c.Secure}
+	switch c.SameSite {
+	case "Lax":
+		hc.SameSite = http.SameSiteLaxMode
+	case "Strict":
+		hc.SameSite = http.SameSiteStrictMode
+	case "None":
+		hc.SameSite = http.SameSiteNoneMode
+	}
+	return hc
+}
+
 func GetContentMime(header http.Header) string {
 	contentMIME := strings.Split(header.Get("Content-Type"), ";")[0]
 	if contentMIME == "" {
@@ -379,23 +622,28 @@ func (cw *Crawler) LoadPages(folderpath string) (int, error) {
 			return readCount, err
 		}
 
-		url := p.URL
-		if cw.BeforeCrawlFn != nil {
-			url, _ = cw.BeforeCrawlFn(url)
-		}
-
-		links := p.RespInfo.Hrefs
-		if cw.AfterCrawlFn != nil {
-			links, _ = cw.AfterCrawlFn(p, err)
-		}
-
-		cw.AddCrawledLinks([]string{url})
-		cw.AddAllLinks(links)
+		cw.AddPage(p)
 		readCount += 1
 	}
 	return readCount, nil
 }
 
+// AddPage marks the page url as crawled and adds its links to the frontier
+func (cw *Crawler) AddPage(p *Page) {
+	url := p.URL
+	if cw.BeforeCrawlFn != nil {
+		url, _ = cw.BeforeCrawlFn(url)
+	}
+
+	links := LinksFromPage(p)
+	if cw.AfterCrawlFn != nil {
+		links, _ = cw.AfterCrawlFn(p, nil)
+	}
+
+	cw.AddCrawledLinks([]string{url})
+	cw.AddAllLinks(links)
+}
+
 func (cw *Crawler) RemoveLinksNotSameHost(baseUrl *url.URL) {
 	for k, _ := range cw.Links {
 		pUrl, err := url.Parse(k)
@@ -430,6 +678,26 @@ func LocationFromPage(page *Page, baseUrl *url.URL) (bool, string) {
 	return false, ""
 }
 
+// MetaRefreshFromPage treats a meta http-equiv refresh like a redirect
+func MetaRefreshFromPage(page *Page) (bool, string) {
+	if page.RespInfo.MetaRefresh != "" {
+		return true, page.RespInfo.MetaRefresh
+	}
+	return false, ""
+}
+
+// AddRedirectHrefs adds the Location and meta refresh targets to the hrefs
+func AddRedirectHrefs(page *Page, baseUrl *url.URL) {
+	isRedirect, location := LocationFromPage(page, baseUrl)
+	if isRedirect && !ContainsString(page.RespInfo.Hrefs, location) {
+		page.RespInfo.Hrefs = append(page.RespInfo.Hrefs, location)
+	}
+	isRefresh, refresh := MetaRefreshFromPage(page)
+	if isRefresh && !ContainsString(page.RespInfo.Hrefs, refresh) {
+		page.RespInfo.Hrefs = append(page.RespInfo.Hrefs, refresh)
+	}
+}
+
 func GetPageInfoFiles(folder string) ([]string, error) {
 	files, err := ioutil.ReadDir(folder)
 	paths := []string{}
@@ -438,17 +706,21 @@ func GetPageInfoFiles(folder string) ([]string, error) {
 	}
 
 	for _, file := range files {
-		isHttpi := strings.HasSuffix(file.Name(), ".httpi")
+		name := trimCompressionSuffix(file.Name())
+		isHttpi := strings.HasSuffix(name, ".httpi")
 		if !isHttpi {
 			continue
 		}
-		paths = append(paths, path.Join(folder, file.Name()))
+		paths = append(paths, path.Join(folder, name))
 	}
 	return paths, nil
 }
 
+// LoadPage reads a stored page, filepath may be the name on disk like
+// 1.httpi.gz
 func LoadPage(filepath string, withContent bool) (*Page, error) {
-	content, err := ioutil.ReadFile(filepath)
+	filepath = trimCompressionSuffix(filepath)
+	content, err := readStorageFile(filepath)
 	if err != nil {
 		return nil, err
 	}
@@ -459,17 +731,56 @@ func LoadPage(filepath string, withContent bool) (*Page, error) {
 		return nil, err
 	}
 	if withContent {
-		respbinfile := strings.Replace(filepath, ".httpi", ".respbin", 1)
-		respbinContent, err := ioutil.ReadFile(respbinfile)
+		respbinContent, err := loadPageBody(filepath, &page)
 		if err != nil {
 			log.Println(err)
 		}
 		page.ResponseBody = respbinContent
+
+		reqbinfile := strings.Replace(filepath, ".httpi", ".reqbin", 1)
+		reqbinContent, err := readStorageFile(reqbinfile)
+		if err != nil && !os.IsNotExist(err) {
+			log.Println(err)
+		}
+		page.RequestBody = reqbinContent
 	}
 
 	return &page, nil
 }
 
+// loadPageBody reads the body of a stored page, deduplicated bodies are
+// read from the body store
+func loadPageBody(filepath string, page *Page) ([]byte, error) {
+	respbinfile := strings.Replace(filepath, ".httpi", ".respbin", 1)
+	respbinContent, err := readStorageFile(respbinfile)
+	if os.IsNotExist(err) && page.BodyHash != "" {
+		bodyfile := path.Join(path.Dir(filepath), bodyStoreFolder, page.BodyHash+".respbin")
+		respbinContent, err = readStorageFile(bodyfile)
+	}
+	return respbinContent, err
+}
+
+// LoadAllPages loads every page of a storage folder, filter may be nil
+func LoadAllPages(folder string, withContent bool, filter func(*Page) bool) ([]*Page, error) {
+	files, err := GetPageInfoFiles(folder)
+	if err != nil {
+		return nil, err
+	}
+
+	pages := []*Page{}
+	for _, file := range files {
+		p, err := LoadPage(file, withContent)
+		if err != nil {
+			return pages, err
+		}
+		if filter != nil && !filter(p) {
+			continue
+		}
+		pages = append(pages, p)
+	}
+	return pages, nil
+}
+
 func (c *Crawler) SavePage(page *Page) {
 	if c.StorageFolder == "" {
 		// dont save if storagepath is empty
@@ -478,21 +789,41 @@ func (c *Crawler) SavePage(page *Page) {
 	if page == nil {
 		log.Fatal("SavePage: page is null")
 	}
-	_, err := os.Stat("./storage")
-	if err != nil && os.IsNotExist(err) {
-		err := os.Mkdir("storage", 0777)
-		checkFatal(err)
-	}
+	err := os.MkdirAll(c.StorageFolder, 0777)
+	checkFatal(err)
 
 	fileName := strconv.FormatInt(int64(page.CrawlTime), 10)
-	filePath := path.Join(c.StorageFolder, fileName+".respbin")
-	err = ioutil.WriteFile(filePath, page.ResponseBody, 0666)
+	if c.DedupBodies {
+		err = c.saveBodyDedup(page)
+	} else {
+		filePath := path.Join(c.StorageFolder, fileName+".respbin")
+		err = writeStorageFile(filePath, page.ResponseBody, c.Compression)
+	}
 	checkFatal(err)
 
-	content, err := json.MarshalIndent(page, "", "  ")
+	if len(page.RequestBody) > 0 {
+		filePath := path.Join(c.StorageFolder, fileName+".reqbin")
+		err = writeStorageFile(filePath, page.RequestBody, c.Compression)
+		checkFatal(err)
+	}
+
+	var content []byte
+	if c.Compression == CompressionNone {
+		content, err = json.MarshalIndent(page, "", "  ")
+	} else {
+		content, err = json.Marshal(page)
+	}
+	checkFatal(err)
+	filePath := path.Join(c.StorageFolder, fileName+".httpi")
+	err = writeStorageFile(filePath, content, c.Compression)
 	checkFatal(err)
-	filePath = path.Join(c.StorageFolder, fileName+".httpi")
-	err = ioutil.WriteFile(filePath, content, 0666)
+
+	if c.Index != nil {
+		err = c.Index.Add(filePath, page)
+		if err != nil {
+			log.Println("SavePage: index ", err)
+		}
+	}
 
 	/*content, err = json.MarshalIndent(page.RespInfo, "", "  ")
 	checkError(err)
@@ -506,7 +837,7 @@ func checkFatal(e error) {
 	}
 }
 
-var regFindUrl *regexp.Regexp = regexp.MustCompile("[a-zA-Z]?:?//[a-zA-Z0-9.-]+/?[a-zA-Z0-9+&@#/%?=~_()|!:,.;]*")
+var regFindUrl *regexp.Regexp = regexp.MustCompile("(?:[a-zA-Z][a-zA-Z0-9+.-]*:)?//[a-zA-Z0-9.-]+/?[a-zA-Z0-9+&@#/%?=~_()|!:,.;]*")
 var regFindWord *regexp.Regexp = regexp.MustCompile("[a-zA-Z]{3,}")
 var regFindIP *regexp.Regexp = regexp.MustCompile(`\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3}`)
 
@@ -561,18 +892,20 @@ func GetRessources(doc *goquery.Document, baseUrl *url.URL) []Ressource {
 		ressources = append(ressources, script)
 	})
 	doc.Find("style").Each(func(i int, s *goquery.Selection) {
-		style := Ressource{}
-		style.Tag = "style"
-		if href, exists := s.Attr("src"); exists {
-			style.Url = ToAbsUrl(baseUrl, href)
-		} else {
-			return
+		for _, res := range GetRessourcesFromCss(s.Text(), baseUrl) {
+			res.Tag = "style"
+			ressources = append(ressources, res)
 		}
-		if styleType, exists := s.Attr("type"); exists {
-			style.Type = styleType
+	})
+	doc.Find("[style]").Each(func(i int, s *goquery.Selection) {
+		style, _ := s.Attr("style")
+		for _, res := range GetRessourcesFromCss(style, baseUrl) {
+			res.Tag = goquery.NodeName(s)
+			res.Source = "style"
+			ressources = append(ressources, res)
 		}
-		ressources = append(ressources, style)
 	})
+	ressources = append(ressources, GetMediaRessources(doc, baseUrl)...)
 	return ressources
 }
 
@@ -605,15 +938,18 @@ func IsVisibleCss(style string) bool {
 
 func GetHrefs(doc *goquery.Document, baseUrl *url.URL, removeInvisibles bool) []string {
 	hrefs := []string{}
+	rules := StyleRules{}
+	if removeInvisibles {
+		rules = GetDocumentStyleRules(doc)
+	}
 
 	doc.Find("a").Each(func(i int, s *goquery.Selection) {
 		href, exists := s.Attr("href")
 		if !exists {
 			return
 		}
-		style, hasStyle := s.Attr("style")
-		if removeInvisibles && hasStyle {
-			if IsVisibleCss(style) {
+		if removeInvisibles {
+			if hidden, _ := IsHiddenElement(s, rules); hidden {
 				return
 			}
 		}
@@ -647,20 +983,23 @@ func GetFormUrls(doc *goquery.Document, baseUrl *url.URL) []Form {
 		if method, exists := s.Attr("method"); exists {
 			form.Method = method
 		}
+		form.Enctype, _ = s.Attr("enctype")
+		form.AcceptCharset, _ = s.Attr("accept-charset")
+		form.Id, _ = s.Attr("id")
+		form.Name, _ = s.Attr("name")
+
+		formNode := s.Get(0)
 		form.Inputs = []FormInput{}
-		s.Find("input").Each(func(i int, s *goquery.Selection) {
-			input := FormInput{}
-			if name, exists := s.Attr("name"); exists {
-				input.Name = name
-			}
-			if value, exists := s.Attr("value"); exists {
-				input.Value = value
-			}
-			if inputType, exists := s.Attr("type"); exists {
-				input.Type = inputType
+		doc.Find("input, select, textarea, button").Each(func(i int, s *goquery.Selection) {
+			// inputs can be placed outside of the form with the form attribute
+			if formId, exists := s.Attr("form"); exists {
+				if form.Id == "" || formId != form.Id {
+					return
+				}
+			} else if s.Closest("form").Get(0) != formNode {
+				return
 			}
-
-			form.Inputs = append(form.Inputs, input)
+			form.Inputs = append(form.Inputs, GetFormInput(s, baseUrl))
 		})
 
 		forms = append(forms, form)
@@ -668,6 +1007,68 @@ func GetFormUrls(doc *goquery.Document, baseUrl *url.URL) []Form {
 	return forms
 }
 
+func GetFormInput(s *goquery.Selection, baseUrl *url.URL) FormInput {
+	input := FormInput{}
+	input.Tag = goquery.NodeName(s)
+	if name, exists := s.Attr("name"); exists {
+		input.Name = name
+	}
+	if value, exists := s.Attr("value"); exists {
+		input.Value = value
+	}
+	if inputType, exists := s.Attr("type"); exists {
+		input.Type = inputType
+	}
+	_, input.Required = s.Attr("required")
+	_, input.Checked = s.Attr("checked")
+	_, input.Multiple = s.Attr("multiple")
+	input.Pattern, _ = s.Attr("pattern")
+	if maxLength, exists := s.Attr("maxlength"); exists {
+		input.MaxLength, _ = strconv.Atoi(maxLength)
+	}
+
+	switch input.Tag {
+	case "textarea":
+		input.Value = s.Text()
+	case "button":
+		if input.Type == "" {
+			input.Type = "submit"
+		}
+	case "select":
+		s.Find("option").Each(func(i int, s *goquery.Selection) {
+			option := FormOption{Text: strings.TrimSpace(s.Text())}
+			value, exists := s.Attr("value")
+			if !exists {
+				value = option.Text
+			}
+			option.Value = value
+			_, option.Selected = s.Attr("selected")
+			input.Options = append(input.Options, option)
+		})
+		input.Value = selectValue(input)
+	}
+
+	if formAction, exists := s.Attr("formaction"); exists {
+		input.FormAction = ToAbsUrl(baseUrl, formAction)
+	}
+	input.FormMethod, _ = s.Attr("formmethod")
+	input.FormEnctype, _ = s.Attr("formenctype")
+	return input
+}
+
+// selectValue returns the value a browser submits for a single select
+func selectValue(input FormInput) string {
+	for _, option := range input.Options {
+		if option.Selected {
+			return option.Value
+		}
+	}
+	if !input.Multiple && len(input.Options) > 0 {
+		return input.Options[0].Value
+	}
+	return ""
+}
+
 func (ds *DNSScanner) LoadConfigFromFile(name string) error {
 	var err error
 	ds.config, err = dns.ClientConfigFromFile(name)
@@ -733,12 +1134,32 @@ func (ds *DNSScanner) ResolveDNS(name string, dnsType uint16) ([]string, error)
 	return resp, nil
 }
 
+// GetBaseUrl returns the url relative links resolve against, the base
+// element overrides the page url
+func GetBaseUrl(doc *goquery.Document, pageUrl *url.URL) *url.URL {
+	href, exists := doc.Find("base[href]").First().Attr("href")
+	if !exists {
+		return pageUrl
+	}
+	baseUrl, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return pageUrl
+	}
+	if pageUrl != nil {
+		baseUrl = pageUrl.ResolveReference(baseUrl)
+	}
+	return baseUrl
+}
+
 func ToAbsUrl(baseurl *url.URL, weburl string) string {
 	relurl, err := url.Parse(weburl)
 	if err != nil {
 		return ""
 	}
 
+	if baseurl == nil {
+		return relurl.String()
+	}
 	absurl := baseurl.ResolveReference(relurl)
 	return absurl.String()
 }
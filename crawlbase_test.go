@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"net/http"
 	"net/url"
+	"strings"
 	"testing"
 )
 import "github.com/PuerkitoBio/goquery"
@@ -33,7 +34,7 @@ func TestGetAllHrefs(t *testing.T) {
 	ioreader := bytes.NewReader([]byte(str))
 	doc, _ := goquery.NewDocumentFromReader(ioreader)
 	testUrl, _ := url.Parse("http://test.com")
-	links := GetHrefs(doc, testUrl, true)
+	links := GetHrefs(doc, testUrl, false)
 	if len(links) != 2 {
 		t.Error(links)
 	}
@@ -44,8 +45,8 @@ func TestGetVisibleHrefs(t *testing.T) {
 	ioreader := bytes.NewReader([]byte(str))
 	doc, _ := goquery.NewDocumentFromReader(ioreader)
 	testUrl, _ := url.Parse("http://test.com")
-	links := GetHrefs(doc, testUrl, false)
-	if len(links) == 1 {
+	links := GetHrefs(doc, testUrl, true)
+	if len(links) != 1 {
 		t.Error("incorrect link count")
 	}
 }
@@ -151,3 +152,105 @@ func TestAbsUrl(t *testing.T) {
 		t.Error("toAbsUrl incorrect " + abs)
 	}
 }
+
+func TestGetFormUrls(t *testing.T) {
+	str := `<form id="f" action="/search" method="post" enctype="multipart/form-data">
+	<input name="q" required maxlength="20" pattern="[a-z]+">
+	<input type="checkbox" name="all" checked>
+	<select name="lang"><option>de</option><option value="en" selected>English</option></select>
+	<textarea name="text">hello</textarea>
+	<button name="go" value="1" formaction="/advanced">go</button>
+	</form>
+	<input name="outside" form="f">`
+	doc, _ := goquery.NewDocumentFromReader(bytes.NewReader([]byte(str)))
+	testUrl, _ := url.Parse("http://test.com")
+	forms := GetFormUrls(doc, testUrl)
+	if len(forms) != 1 {
+		t.Fatal("incorrect form count")
+	}
+
+	form := forms[0]
+	if form.Enctype != "multipart/form-data" || len(form.Inputs) != 6 {
+		t.Fatal("incorrect form: ", form)
+	}
+	q := form.Inputs[0]
+	if !q.Required || q.MaxLength != 20 || q.Pattern != "[a-z]+" {
+		t.Error("input constraints missing: ", q)
+	}
+	if !form.Inputs[1].Checked {
+		t.Error("checked state missing")
+	}
+	if form.Inputs[2].Value != "en" || len(form.Inputs[2].Options) != 2 {
+		t.Error("incorrect select: ", form.Inputs[2])
+	}
+	if form.Inputs[3].Value != "hello" {
+		t.Error("textarea content missing")
+	}
+	if form.Inputs[4].Type != "submit" || form.Inputs[4].FormAction != "http://test.com/advanced" {
+		t.Error("incorrect button: ", form.Inputs[4])
+	}
+	if form.Inputs[5].Name != "outside" {
+		t.Error("input associated by form attribute missing")
+	}
+}
+
+func TestGetRessources(t *testing.T) {
+	str := `<html manifest="app.appcache"><head>
+	<meta http-equiv="refresh" content="5; url='/next'">
+	<style>@import "print.css"; body { background: url(bg.png) }</style>
+	<link rel="preload" href="/font.woff2"></head><body>
+	<img src="a.png" srcset="a-2x.png 2x, a-3x.png 3x">
+	<picture><source srcset="b.webp" type="image/webp"></picture>
+	<iframe src="/frame"></iframe>
+	<video src="v.mp4" poster="p.jpg"><track src="t.vtt"></video>
+	<object data="o.swf"></object><embed src="e.swf">
+	<svg><image xlink:href="s.svg"></image></svg>
+	<div style="background-image: url('div.png')"></div>
+	</body></html>`
+	doc, _ := goquery.NewDocumentFromReader(bytes.NewReader([]byte(str)))
+	testUrl, _ := url.Parse("http://test.com/")
+
+	urls := []string{}
+	for _, res := range GetRessources(doc, testUrl) {
+		urls = append(urls, res.Url)
+	}
+	expected := []string{"app.appcache", "next", "print.css", "bg.png", "font.woff2", "a.png", "a-2x.png", "a-3x.png",
+		"b.webp", "frame", "v.mp4", "p.jpg", "t.vtt", "o.swf", "e.swf", "s.svg", "div.png"}
+	for _, u := range expected {
+		if !ContainsString(urls, "http://test.com/"+u) {
+			t.Error("missing ressource ", u, " in ", urls)
+		}
+	}
+}
+
+func TestParseSrcset(t *testing.T) {
+	srcset := "image.php?w=1,2 2x, data:image/png;base64,AAA= 1x,b.png 100w,c.png,, d.png (min-width: 1px, foo) 3x"
+	urls := ParseSrcset(srcset)
+	expected := []string{"image.php?w=1,2", "data:image/png;base64,AAA=", "b.png", "c.png", "d.png"}
+	if strings.Join(urls, " ") != strings.Join(expected, " ") {
+		t.Error("wrong srcset urls ", urls)
+	}
+}
+
+func TestPageFromDataBaseHref(t *testing.T) {
+	str := `<head><base href="/static/"><meta http-equiv="refresh" content="0;URL=next.html"></head>
+	<a href="page.html"></a><form action="send"></form><img src="img.png">`
+	testUrl, _ := url.Parse("http://test.com/dir/index.html")
+	p := PageFromData([]byte(str), testUrl, false)
+
+	if !ContainsString(p.RespInfo.Hrefs, "http://test.com/static/page.html") {
+		t.Error("base href not applied to links: ", p.RespInfo.Hrefs)
+	}
+	if p.RespInfo.Forms[0].Url != "http://test.com/static/send" {
+		t.Error("base href not applied to forms: ", p.RespInfo.Forms[0].Url)
+	}
+	if p.RespInfo.MetaRefresh != "http://test.com/static/next.html" {
+		t.Error("meta refresh not extracted: ", p.RespInfo.MetaRefresh)
+	}
+
+	p.Response.Header = http.Header{}
+	AddRedirectHrefs(p, testUrl)
+	if !ContainsString(p.RespInfo.Hrefs, p.RespInfo.MetaRefresh) {
+		t.Error("meta refresh not added as redirect")
+	}
+}
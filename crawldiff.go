@@ -0,0 +1,272 @@
+package crawlbase
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+type CrawlDiff struct {
+	OldFolder string
+	NewFolder string
+	Added     []string
+	Removed   []string
+	Pages     []PageDiff
+}
+
+type PageDiff struct {
+	PageChange
+	InputsAdded       []string       `json:",omitempty"`
+	InputsRemoved     []string       `json:",omitempty"`
+	RessourcesAdded   []string       `json:",omitempty"`
+	RessourcesRemoved []string       `json:",omitempty"`
+	ScriptsAdded      []string       `json:",omitempty"`
+	ScriptsRemoved    []string       `json:",omitempty"`
+	CookiesChanged    []CookieChange `json:",omitempty"`
+}
+
+// CookieChange lists the cookie flags of both crawls, empty if the cookie
+// was not set
+type CookieChange struct {
+	Name     string
+	OldFlags string
+	NewFlags string
+}
+
+// CanonicalUrl normalizes an url for matching pages of different crawls
+func CanonicalUrl(rawUrl string) string {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return rawUrl
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	if (u.Scheme == "http" && strings.HasSuffix(u.Host, ":80")) ||
+		(u.Scheme == "https" && strings.HasSuffix(u.Host, ":443")) {
+		u.Host = u.Host[:strings.LastIndex(u.Host, ":")]
+	}
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	u.Fragment = ""
+	u.RawQuery = u.Query().Encode()
+	return u.String()
+}
+
+func pagesByCanonicalUrl(pages []*Page) map[string]*Page {
+	byUrl := map[string]*Page{}
+	for _, page := range pages {
+		key := CanonicalUrl(page.URL)
+		prev, hasPrev := byUrl[key]
+		if hasPrev && prev.CrawlTime > page.CrawlTime {
+			continue
+		}
+		byUrl[key] = page
+	}
+	return byUrl
+}
+
+// DiffCrawls compares the pages of two storage folders
+func DiffCrawls(oldFolder, newFolder string) (*CrawlDiff, error) {
+	oldPages, err := LoadAllPages(oldFolder, false, nil)
+	if err != nil {
+		return nil, err
+	}
+	newPages, err := LoadAllPages(newFolder, false, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := DiffPageSets(oldPages, newPages)
+	diff.OldFolder = oldFolder
+	diff.NewFolder = newFolder
+	return diff, nil
+}
+
+func DiffPageSets(oldPages, newPages []*Page) *CrawlDiff {
+	diff := &CrawlDiff{Added: []string{}, Removed: []string{}, Pages: []PageDiff{}}
+	oldByUrl := pagesByCanonicalUrl(oldPages)
+	newByUrl := pagesByCanonicalUrl(newPages)
+
+	keys := []string{}
+	for key := range newByUrl {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		oldPage, hasOld := oldByUrl[key]
+		if !hasOld {
+			diff.Added = append(diff.Added, key)
+			continue
+		}
+		pd := DiffCrawledPages(oldPage, newByUrl[key])
+		if pd.HasChanges() {
+			diff.Pages = append(diff.Pages, pd)
+		}
+	}
+	for key := range oldByUrl {
+		if _, hasNew := newByUrl[key]; !hasNew {
+			diff.Removed = append(diff.Removed, key)
+		}
+	}
+	sort.Strings(diff.Removed)
+	return diff
+}
+
+func DiffCrawledPages(oldPage, newPage *Page) PageDiff {
+	pd := PageDiff{PageChange: DiffPages(oldPage, newPage)}
+	pd.URL = CanonicalUrl(newPage.URL)
+
+	pd.InputsAdded, pd.InputsRemoved = DiffStrings(formInputKeys(oldPage.RespInfo.Forms), formInputKeys(newPage.RespInfo.Forms))
+
+	oldRes, oldScripts := ressourceUrls(oldPage.RespInfo.Ressources)
+	newRes, newScripts := ressourceUrls(newPage.RespInfo.Ressources)
+	pd.RessourcesAdded, pd.RessourcesRemoved = DiffStrings(oldRes, newRes)
+	pd.ScriptsAdded, pd.ScriptsRemoved = DiffStrings(oldScripts, newScripts)
+
+	pd.CookiesChanged = DiffCookieFlags(oldPage.Response, newPage.Response)
+	return pd
+}
+
+func (pd *PageDiff) HasChanges() bool {
+	return pd.PageChange.HasChanges() ||
+		len(pd.InputsAdded) > 0 || len(pd.InputsRemoved) > 0 ||
+		len(pd.RessourcesAdded) > 0 || len(pd.RessourcesRemoved) > 0 ||
+		len(pd.ScriptsAdded) > 0 || len(pd.ScriptsRemoved) > 0 ||
+		len(pd.CookiesChanged) > 0
+}
+
+// formInputKeys identifies inputs by form method, action and input name
+func formInputKeys(forms []Form) []string {
+	keys := []string{}
+	for _, form := range forms {
+		for _, input := range form.Inputs {
+			keys = append(keys, strings.ToUpper(form.Method)+" "+form.Url+" "+input.Name)
+		}
+	}
+	return keys
+}
+
+func ressourceUrls(ressources []Ressource) (urls []string, scripts []string) {
+	for _, res := range ressources {
+		if res.Url == "" {
+			continue
+		}
+		if res.Tag == "script" {
+			scripts = append(scripts, res.Url)
+		} else {
+			urls = append(urls, res.Url)
+		}
+	}
+	return urls, scripts
+}
+
+func DiffCookieFlags(oldRes, newRes *PageResponse) []CookieChange {
+	oldFlags := cookieFlagsByName(oldRes)
+	newFlags := cookieFlagsByName(newRes)
+
+	names := []string{}
+	for name := range oldFlags {
+		names = append(names, name)
+	}
+	for name := range newFlags {
+		if _, hasOld := oldFlags[name]; !hasOld {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	changes := []CookieChange{}
+	for _, name := range names {
+		if oldFlags[name] != newFlags[name] {
+			changes = append(changes, CookieChange{Name: name, OldFlags: oldFlags[name], NewFlags: newFlags[name]})
+		}
+	}
+	return changes
+}
+
+func cookieFlagsByName(res *PageResponse) map[string]string {
+	flags := map[string]string{}
+	if res == nil {
+		return flags
+	}
+	for _, c := range res.Cookies {
+		flags[c.Name] = CookieFlags(c.HTTPCookie())
+	}
+	httpRes := http.Response{Header: res.Header}
+	for _, c := range httpRes.Cookies() {
+		flags[c.Name] = CookieFlags(c)
+	}
+	return flags
+}
+
+func CookieFlags(c *http.Cookie) string {
+	flags := []string{"set"}
+	if c.Secure {
+		flags = append(flags, "Secure")
+	}
+	if c.HttpOnly {
+		flags = append(flags, "HttpOnly")
+	}
+	switch c.SameSite {
+	case http.SameSiteLaxMode:
+		flags = append(flags, "SameSite=Lax")
+	case http.SameSiteStrictMode:
+		flags = append(flags, "SameSite=Strict")
+	case http.SameSiteNoneMode:
+		flags = append(flags, "SameSite=None")
+	}
+	return strings.Join(flags, "; ")
+}
+
+func (diff *CrawlDiff) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(diff)
+}
+
+func (diff *CrawlDiff) Summary() string {
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "%d added, %d removed, %d changed page(s)\n", len(diff.Added), len(diff.Removed), len(diff.Pages))
+	for _, u := range diff.Added {
+		fmt.Fprintln(sb, "+", u)
+	}
+	for _, u := range diff.Removed {
+		fmt.Fprintln(sb, "-", u)
+	}
+	for _, pd := range diff.Pages {
+		fmt.Fprintln(sb, "~", pd.URL)
+		if pd.OldStatus != pd.NewStatus {
+			fmt.Fprintf(sb, "    status %d -> %d\n", pd.OldStatus, pd.NewStatus)
+		}
+		writeSummaryList(sb, "header added", pd.HeadersAdded)
+		writeSummaryList(sb, "header removed", pd.HeadersRemoved)
+		writeSummaryList(sb, "header changed", pd.HeadersChanged)
+		writeSummaryList(sb, "form added", pd.FormsAdded)
+		writeSummaryList(sb, "form removed", pd.FormsRemoved)
+		writeSummaryList(sb, "input added", pd.InputsAdded)
+		writeSummaryList(sb, "input removed", pd.InputsRemoved)
+		writeSummaryList(sb, "script added", pd.ScriptsAdded)
+		writeSummaryList(sb, "script removed", pd.ScriptsRemoved)
+		writeSummaryList(sb, "ressource added", pd.RessourcesAdded)
+		writeSummaryList(sb, "ressource removed", pd.RessourcesRemoved)
+		for _, cc := range pd.CookiesChanged {
+			fmt.Fprintf(sb, "    cookie %s: %q -> %q\n", cc.Name, cc.OldFlags, cc.NewFlags)
+		}
+		if pd.OldBodyHash != pd.NewBodyHash {
+			fmt.Fprintln(sb, "    body changed")
+		}
+	}
+	return sb.String()
+}
+
+func writeSummaryList(w io.Writer, label string, values []string) {
+	for _, v := range values {
+		fmt.Fprintf(w, "    %s: %s\n", label, v)
+	}
+}
@@ -0,0 +1,42 @@
+package crawlbase
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestCanonicalUrl(t *testing.T) {
+	canonical := CanonicalUrl("HTTP://Test.com:80?b=2&a=1#top")
+	if canonical != "http://test.com/?a=1&b=2" {
+		t.Error("incorrect canonical url: ", canonical)
+	}
+}
+
+func TestDiffPageSets(t *testing.T) {
+	oldPage := &Page{URL: "http://test.com/", Response: &PageResponse{StatusCode: 200, Header: http.Header{}}}
+	oldPage.Response.Header.Add("Set-Cookie", "sid=1; Secure; HttpOnly")
+	oldPage.RespInfo.Forms = []Form{{Url: "http://test.com/search", Method: "get", Inputs: []FormInput{{Name: "q"}}}}
+	removedPage := &Page{URL: "http://test.com/old", Response: &PageResponse{StatusCode: 200}}
+
+	newPage := &Page{URL: "http://test.com", Response: &PageResponse{StatusCode: 500, Header: http.Header{}}}
+	newPage.Response.Header.Add("Set-Cookie", "sid=2")
+	newPage.RespInfo.Forms = []Form{{Url: "http://test.com/search", Method: "get", Inputs: []FormInput{{Name: "q"}, {Name: "page"}}}}
+	newPage.RespInfo.Ressources = []Ressource{{Url: "http://test.com/app.js", Tag: "script"}}
+	addedPage := &Page{URL: "http://test.com/new", Response: &PageResponse{StatusCode: 200}}
+
+	diff := DiffPageSets([]*Page{oldPage, removedPage}, []*Page{newPage, addedPage})
+	if len(diff.Added) != 1 || len(diff.Removed) != 1 || len(diff.Pages) != 1 {
+		t.Fatal("incorrect diff: ", diff.Summary())
+	}
+
+	pd := diff.Pages[0]
+	if pd.OldStatus != 200 || pd.NewStatus != 500 {
+		t.Error("status change not detected")
+	}
+	if len(pd.InputsAdded) != 1 || len(pd.ScriptsAdded) != 1 {
+		t.Error("input or script change not detected")
+	}
+	if len(pd.CookiesChanged) != 1 || pd.CookiesChanged[0].NewFlags != "set" {
+		t.Error("cookie flag change not detected: ", pd.CookiesChanged)
+	}
+}
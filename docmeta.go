@@ -0,0 +1,170 @@
+package crawlbase
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// DocumentMeta holds the seo relevant metadata of a html page
+type DocumentMeta struct {
+	Title       string
+	Description string            `json:",omitempty"`
+	Robots      string            `json:",omitempty"`
+	Canonical   string            `json:",omitempty"`
+	Lang        string            `json:",omitempty"`
+	Alternates  []Alternate       `json:",omitempty"`
+	OpenGraph   map[string]string `json:",omitempty"`
+	Twitter     map[string]string `json:",omitempty"`
+	JSONLD      []string          `json:",omitempty"`
+	Microdata   []MicrodataItem   `json:",omitempty"`
+	Headings    []Heading         `json:",omitempty"`
+}
+
+// Alternate is a link rel=alternate with a hreflang
+type Alternate struct {
+	Lang string
+	Url  string
+}
+
+type MicrodataItem struct {
+	Type  string
+	Props map[string][]string
+}
+
+type Heading struct {
+	Level int
+	Text  string
+}
+
+// GetDocumentMeta extracts title, meta tags, canonical, hreflang alternates,
+// open graph and twitter cards, json-ld, microdata and headings
+func GetDocumentMeta(doc *goquery.Document, baseUrl *url.URL) *DocumentMeta {
+	meta := &DocumentMeta{OpenGraph: map[string]string{}, Twitter: map[string]string{}}
+
+	meta.Title = strings.TrimSpace(doc.Find("title").First().Text())
+	meta.Lang, _ = doc.Find("html").First().Attr("lang")
+
+	doc.Find("meta").Each(func(i int, s *goquery.Selection) {
+		content, _ := s.Attr("content")
+		content = strings.TrimSpace(content)
+		name, _ := s.Attr("name")
+		property, _ := s.Attr("property")
+		name = strings.ToLower(strings.TrimSpace(name))
+		property = strings.ToLower(strings.TrimSpace(property))
+
+		switch {
+		case name == "description":
+			meta.Description = content
+		case name == "robots":
+			meta.Robots = content
+		case strings.HasPrefix(property, "og:"):
+			meta.OpenGraph[property[3:]] = content
+		case strings.HasPrefix(name, "twitter:"):
+			meta.Twitter[name[8:]] = content
+		case strings.HasPrefix(property, "twitter:"):
+			meta.Twitter[property[8:]] = content
+		}
+	})
+
+	doc.Find("link[rel][href]").Each(func(i int, s *goquery.Selection) {
+		rel, _ := s.Attr("rel")
+		href, _ := s.Attr("href")
+		rels := strings.Fields(strings.ToLower(rel))
+		if ContainsString(rels, "canonical") && meta.Canonical == "" {
+			meta.Canonical = ToAbsUrl(baseUrl, strings.TrimSpace(href))
+		}
+		if lang, ok := s.Attr("hreflang"); ok && ContainsString(rels, "alternate") {
+			meta.Alternates = append(meta.Alternates, Alternate{Lang: lang, Url: ToAbsUrl(baseUrl, strings.TrimSpace(href))})
+		}
+	})
+
+	doc.Find(`script[type="application/ld+json"]`).Each(func(i int, s *goquery.Selection) {
+		if code := strings.TrimSpace(s.Text()); code != "" {
+			meta.JSONLD = append(meta.JSONLD, code)
+		}
+	})
+
+	doc.Find("[itemscope]").Each(func(i int, s *goquery.Selection) {
+		item := MicrodataItem{Props: map[string][]string{}}
+		item.Type, _ = s.Attr("itemtype")
+		s.Find("[itemprop]").Each(func(j int, prop *goquery.Selection) {
+			// properties of nested items belong to the nested item
+			if !prop.Parent().Closest("[itemscope]").IsSelection(s) {
+				return
+			}
+			name, _ := prop.Attr("itemprop")
+			item.Props[name] = append(item.Props[name], microdataValue(prop, baseUrl))
+		})
+		meta.Microdata = append(meta.Microdata, item)
+	})
+
+	doc.Find("h1, h2, h3, h4, h5, h6").Each(func(i int, s *goquery.Selection) {
+		level := int(goquery.NodeName(s)[1] - '0')
+		meta.Headings = append(meta.Headings, Heading{Level: level, Text: strings.Join(strings.Fields(s.Text()), " ")})
+	})
+
+	return meta
+}
+
+func microdataValue(s *goquery.Selection, baseUrl *url.URL) string {
+	if content, ok := s.Attr("content"); ok {
+		return content
+	}
+	switch goquery.NodeName(s) {
+	case "a", "link", "area":
+		href, _ := s.Attr("href")
+		return ToAbsUrl(baseUrl, href)
+	case "img", "audio", "video", "source", "iframe", "embed":
+		src, _ := s.Attr("src")
+		return ToAbsUrl(baseUrl, src)
+	case "meta":
+		return ""
+	case "time":
+		if datetime, ok := s.Attr("datetime"); ok {
+			return datetime
+		}
+	}
+	if _, ok := s.Attr("itemscope"); ok {
+		itemType, _ := s.Attr("itemtype")
+		return itemType
+	}
+	return strings.TrimSpace(s.Text())
+}
+
+// RobotsFromPage returns the noindex and nofollow directives of the meta
+// robots tag and the X-Robots-Tag header
+func RobotsFromPage(page *Page) (noindex bool, nofollow bool) {
+	directives := []string{}
+	if page.RespInfo.Meta != nil {
+		directives = append(directives, page.RespInfo.Meta.Robots)
+	}
+	if page.Response != nil && page.Response.Header != nil {
+		directives = append(directives, page.Response.Header["X-Robots-Tag"]...)
+	}
+	for _, d := range directives {
+		for _, v := range strings.Split(strings.ToLower(d), ",") {
+			switch strings.TrimSpace(v) {
+			case "noindex":
+				noindex = true
+			case "nofollow":
+				nofollow = true
+			case "none":
+				noindex, nofollow = true, true
+			}
+		}
+	}
+	return noindex, nofollow
+}
+
+// CanonicalFromPage returns the canonical url if it differs from the page url
+func CanonicalFromPage(page *Page) (bool, string) {
+	if page.RespInfo.Meta == nil || page.RespInfo.Meta.Canonical == "" {
+		return false, ""
+	}
+	if page.RespInfo.Meta.Canonical == page.URL {
+		return false, ""
+	}
+	return true, page.RespInfo.Meta.Canonical
+}
@@ -0,0 +1,63 @@
+package crawlbase
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestGetDocumentMeta(t *testing.T) {
+	str := `<html lang="de"><head><title> Shop </title>
+	<meta name="description" content="all products">
+	<meta name="robots" content="noindex, nofollow">
+	<meta property="og:title" content="Shop OG">
+	<meta name="twitter:card" content="summary">
+	<link rel="canonical" href="/shop">
+	<link rel="alternate" hreflang="en" href="/en/shop">
+	<script type="application/ld+json">{"@type": "Organization"}</script>
+	</head><body>
+	<h1>Products</h1><h2>New <b>arrivals</b></h2>
+	<div itemscope itemtype="http://schema.org/Product"><span itemprop="name">Chair</span>
+	<div itemprop="offers" itemscope itemtype="http://schema.org/Offer"><span itemprop="price">10</span></div></div>
+	</body></html>`
+	testUrl, _ := url.Parse("http://test.com/shop?page=1")
+	p := PageFromData([]byte(str), testUrl, false)
+	meta := p.RespInfo.Meta
+
+	if meta.Title != "Shop" || meta.Description != "all products" || meta.Lang != "de" {
+		t.Error("wrong title, description or lang ", meta.Title, meta.Description, meta.Lang)
+	}
+	if meta.Canonical != "http://test.com/shop" {
+		t.Error("wrong canonical ", meta.Canonical)
+	}
+	if len(meta.Alternates) != 1 || meta.Alternates[0].Url != "http://test.com/en/shop" {
+		t.Error("wrong alternates ", meta.Alternates)
+	}
+	if meta.OpenGraph["title"] != "Shop OG" || meta.Twitter["card"] != "summary" {
+		t.Error("wrong social tags ", meta.OpenGraph, meta.Twitter)
+	}
+	if len(meta.JSONLD) != 1 {
+		t.Error("json-ld not found")
+	}
+	if len(meta.Headings) != 2 || meta.Headings[1].Level != 2 || meta.Headings[1].Text != "New arrivals" {
+		t.Error("wrong headings ", meta.Headings)
+	}
+	if len(meta.Microdata) != 2 {
+		t.Fatal("wrong microdata ", meta.Microdata)
+	}
+	product := meta.Microdata[0]
+	if product.Props["name"][0] != "Chair" || product.Props["offers"][0] != "http://schema.org/Offer" || len(product.Props["price"]) != 0 {
+		t.Error("wrong product props ", product.Props)
+	}
+
+	p.Response.Header = http.Header{}
+	noindex, nofollow := RobotsFromPage(p)
+	if !noindex || !nofollow {
+		t.Error("robots directives not found")
+	}
+	p.URL = testUrl.String()
+	isCanonical, canonical := CanonicalFromPage(p)
+	if !isCanonical || canonical != "http://test.com/shop" {
+		t.Error("canonical not detected")
+	}
+}
@@ -0,0 +1,301 @@
+package crawlbase
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// Extractor fills the RespInfo of a page from its ResponseBody
+type Extractor func(page *Page, baseUrl *url.URL) error
+
+var extractors = map[string]Extractor{}
+var extractorsLock sync.RWMutex
+
+// mime types parsed as html by PageFromData
+var HtmlMimes = []string{"text/html", "application/xhtml+xml"}
+
+// json and xml values which are urls or absolute paths
+var regJsonUrl *regexp.Regexp = regexp.MustCompile(`^(?:(?:https?|wss?):)?//[^\s]+$|^/[^/\s][^\s]*$`)
+
+func init() {
+	RegisterExtractor("application/json", ExtractJSON)
+	RegisterExtractor("text/json", ExtractJSON)
+	RegisterExtractor("application/hal+json", ExtractJSON)
+	RegisterExtractor("application/vnd.api+json", ExtractJSON)
+	RegisterExtractor("application/xml", ExtractXML)
+	RegisterExtractor("text/xml", ExtractXML)
+	RegisterExtractor("application/rss+xml", ExtractXML)
+	RegisterExtractor("application/atom+xml", ExtractXML)
+	RegisterExtractor("text/plain", ExtractText)
+	RegisterExtractor("application/pdf", ExtractPDF)
+	RegisterExtractor("text/css", ExtractCSS)
+}
+
+// RegisterExtractor sets the extractor for a mime type, it replaces built
+// in extractors. Html is always parsed by PageFromData.
+func RegisterExtractor(mime string, extractor Extractor) {
+	extractorsLock.Lock()
+	defer extractorsLock.Unlock()
+	extractors[strings.ToLower(mime)] = extractor
+}
+
+// GetExtractor returns the extractor of a mime type, types without an own
+// extractor fall back to their +json or +xml suffix. Html types have no
+// extractor.
+func GetExtractor(mime string) Extractor {
+	mime = strings.ToLower(strings.TrimSpace(mime))
+	if ContainsString(HtmlMimes, mime) {
+		return nil
+	}
+	extractorsLock.RLock()
+	defer extractorsLock.RUnlock()
+	if e, ok := extractors[mime]; ok {
+		return e
+	}
+	if strings.HasSuffix(mime, "+json") {
+		return extractors["application/json"]
+	}
+	if strings.HasSuffix(mime, "+xml") {
+		return extractors["application/xml"]
+	}
+	return nil
+}
+
+// PageFromDataMime creates a page with the extractor of the content type,
+// html and unknown types are parsed as html
+func PageFromDataMime(data []byte, pageUrl *url.URL, mime string, includeHiddenLinks bool) *Page {
+	extractor := GetExtractor(mime)
+	if extractor == nil {
+		return PageFromData(data, pageUrl, includeHiddenLinks)
+	}
+
+	page := &Page{}
+	page.ResponseBody = data
+	page.BodyHash = ToHash(string(data))
+	page.Response = &PageResponse{}
+	page.Request = &PageRequest{}
+
+	err := extractor(page, pageUrl)
+	if err != nil {
+		log.Println("PageFromDataMime: ", mime, err)
+	}
+	return page
+}
+
+func addHref(page *Page, u string) {
+	if u != "" && !ContainsString(page.RespInfo.Hrefs, u) {
+		page.RespInfo.Hrefs = append(page.RespInfo.Hrefs, u)
+	}
+}
+
+// ExtractJSON adds url valued strings to the hrefs and HAL _links and
+// JSON:API links as link records with the relation as rel
+func ExtractJSON(page *Page, baseUrl *url.URL) error {
+	var v interface{}
+	err := json.Unmarshal(page.ResponseBody, &v)
+	if err != nil {
+		return err
+	}
+	walkJSON(page, v, baseUrl)
+	return nil
+}
+
+func walkJSON(page *Page, v interface{}, baseUrl *url.URL) {
+	switch value := v.(type) {
+	case string:
+		if regJsonUrl.MatchString(value) {
+			addHref(page, ToAbsUrl(baseUrl, stripUriTemplate(value)))
+		}
+	case []interface{}:
+		for _, item := range value {
+			walkJSON(page, item, baseUrl)
+		}
+	case map[string]interface{}:
+		for key, item := range value {
+			if links, ok := item.(map[string]interface{}); ok && (key == "_links" || key == "links") {
+				addJSONLinks(page, links, baseUrl)
+			}
+			walkJSON(page, item, baseUrl)
+		}
+	}
+}
+
+// addJSONLinks reads {"rel": "/url"}, {"rel": {"href": "/url"}} and
+// {"rel": [{"href": "/url"}]}
+func addJSONLinks(page *Page, links map[string]interface{}, baseUrl *url.URL) {
+	for rel, item := range links {
+		targets := []interface{}{item}
+		if list, ok := item.([]interface{}); ok {
+			targets = list
+		}
+		for _, target := range targets {
+			link := Link{Rel: rel}
+			switch t := target.(type) {
+			case string:
+				link.Url = t
+			case map[string]interface{}:
+				link.Url, _ = t["href"].(string)
+				link.Title, _ = t["title"].(string)
+			}
+			if link.Url == "" {
+				continue
+			}
+			link.Url = ToAbsUrl(baseUrl, stripUriTemplate(link.Url))
+			page.RespInfo.Links = append(page.RespInfo.Links, link)
+			addHref(page, link.Url)
+		}
+	}
+}
+
+// stripUriTemplate cuts HAL templates like /orders{?page}
+func stripUriTemplate(u string) string {
+	if i := strings.Index(u, "{"); i >= 0 {
+		return u[:i]
+	}
+	return u
+}
+
+// ExtractXML adds url attributes and url texts of xml documents, sitemaps
+// and feeds. Feed links get the title of their item or entry as text.
+func ExtractXML(page *Page, baseUrl *url.URL) error {
+	dec := xml.NewDecoder(bytes.NewReader(page.ResponseBody))
+	dec.Strict = false
+	dec.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+
+	title := ""
+	itemLinks := []int{} // links of the current feed item
+	inTitle := false
+	for {
+		token, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			name := strings.ToLower(t.Name.Local)
+			switch name {
+			case "item", "entry":
+				title = ""
+				itemLinks = []int{}
+			case "title":
+				inTitle = true
+			}
+			link := Link{}
+			for _, attr := range t.Attr {
+				switch strings.ToLower(attr.Name.Local) {
+				case "href", "src", "url", "resource":
+					link.Url = ToAbsUrl(baseUrl, strings.TrimSpace(attr.Value))
+				case "rel":
+					link.Rel = attr.Value
+				case "title":
+					link.Title = attr.Value
+				}
+			}
+			if link.Url != "" {
+				addHref(page, link.Url)
+				if name == "link" || name == "enclosure" {
+					page.RespInfo.Links = append(page.RespInfo.Links, link)
+					itemLinks = append(itemLinks, len(page.RespInfo.Links)-1)
+				}
+			}
+		case xml.EndElement:
+			switch strings.ToLower(t.Name.Local) {
+			case "title":
+				inTitle = false
+			case "item", "entry":
+				for _, i := range itemLinks {
+					page.RespInfo.Links[i].Text = title
+				}
+				itemLinks = []int{}
+			}
+		case xml.CharData:
+			text := strings.TrimSpace(string(t))
+			if inTitle {
+				title += text
+			}
+			if regJsonUrl.MatchString(text) && strings.Contains(text, "//") {
+				u := ToAbsUrl(baseUrl, text)
+				addHref(page, u)
+				page.RespInfo.Links = append(page.RespInfo.Links, Link{Url: u})
+				itemLinks = append(itemLinks, len(page.RespInfo.Links)-1)
+			}
+		}
+	}
+	return nil
+}
+
+// ExtractText adds the urls found by GetUrlsFromText
+func ExtractText(page *Page, baseUrl *url.URL) error {
+	for _, u := range GetUrlsFromText(page.ResponseBody, -1) {
+		addHref(page, ToAbsUrl(baseUrl, strings.TrimRight(string(u), ".,;:)")))
+	}
+	return nil
+}
+
+// ExtractCSS adds url() and @import references as ressources
+func ExtractCSS(page *Page, baseUrl *url.URL) error {
+	page.RespInfo.Ressources = GetRessourcesFromCss(string(page.ResponseBody), baseUrl)
+	return nil
+}
+
+// ExtractPDF adds the uri link annotations and the urls of the text
+func ExtractPDF(page *Page, baseUrl *url.URL) (err error) {
+	// the pdf reader panics on some malformed files
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("pdf: %v", r)
+		}
+	}()
+
+	r, err := pdf.NewReader(bytes.NewReader(page.ResponseBody), int64(len(page.ResponseBody)))
+	if err != nil {
+		return err
+	}
+
+	for i := 1; i <= r.NumPage(); i++ {
+		annots := r.Page(i).V.Key("Annots")
+		for j := 0; j < annots.Len(); j++ {
+			annot := annots.Index(j)
+			if annot.Key("Subtype").Name() != "Link" {
+				continue
+			}
+			uri := strings.TrimSpace(annot.Key("A").Key("URI").RawString())
+			if uri == "" {
+				continue
+			}
+			u := ToAbsUrl(baseUrl, uri)
+			page.RespInfo.Links = append(page.RespInfo.Links, Link{Url: u})
+			addHref(page, u)
+		}
+	}
+
+	text, err := r.GetPlainText()
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadAll(text)
+	if err != nil {
+		return err
+	}
+	for _, u := range GetUrlsFromText(data, -1) {
+		addHref(page, ToAbsUrl(baseUrl, strings.TrimRight(string(u), ".,;:)")))
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package crawlbase
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"testing"
+)
+
+func TestExtractJSON(t *testing.T) {
+	str := `{"_links": {"self": {"href": "/orders"}, "next": {"href": "/orders{?page}"}},
+	"data": [{"links": {"related": "https://api.test.com/users/1"}, "name": "not/a url", "avatar": "//cdn.test.com/a.png"}]}`
+	testUrl, _ := url.Parse("http://test.com/api/")
+	page := PageFromDataMime([]byte(str), testUrl, "application/hal+json", false)
+
+	expected := []string{"http://test.com/orders", "https://api.test.com/users/1", "http://cdn.test.com/a.png"}
+	for _, u := range expected {
+		if !ContainsString(page.RespInfo.Hrefs, u) {
+			t.Error("missing ", u, " in ", page.RespInfo.Hrefs)
+		}
+	}
+	if len(page.RespInfo.Hrefs) != 3 {
+		t.Error("wrong hrefs ", page.RespInfo.Hrefs)
+	}
+	rels := []string{}
+	for _, l := range page.RespInfo.Links {
+		rels = append(rels, l.Rel)
+	}
+	if len(rels) != 3 || !ContainsString(rels, "next") || !ContainsString(rels, "related") {
+		t.Error("wrong link relations ", rels)
+	}
+}
+
+func TestExtractFeed(t *testing.T) {
+	str := `<?xml version="1.0" encoding="ISO-8859-1"?>
+	<feed xmlns="http://www.w3.org/2005/Atom"><link rel="self" href="/feed"/>
+	<entry><title>First post</title><link href="/posts/1"/><id>http://test.com/posts/1</id></entry>
+	</feed>`
+	testUrl, _ := url.Parse("http://test.com/")
+	page := PageFromDataMime([]byte(str), testUrl, "application/atom+xml", false)
+
+	if !ContainsString(page.RespInfo.Hrefs, "http://test.com/feed") || !ContainsString(page.RespInfo.Hrefs, "http://test.com/posts/1") {
+		t.Error("wrong hrefs ", page.RespInfo.Hrefs)
+	}
+	if len(page.RespInfo.Links) != 3 || page.RespInfo.Links[0].Rel != "self" || page.RespInfo.Links[1].Text != "First post" {
+		t.Error("wrong links ", page.RespInfo.Links)
+	}
+}
+
+func TestExtractText(t *testing.T) {
+	testUrl, _ := url.Parse("https://test.com/")
+	page := PageFromDataMime([]byte("see https://test.com/docs. and //cdn.test.com/x"), testUrl, "text/plain", false)
+	if len(page.RespInfo.Hrefs) != 2 || page.RespInfo.Hrefs[0] != "https://test.com/docs" || page.RespInfo.Hrefs[1] != "https://cdn.test.com/x" {
+		t.Error("wrong hrefs ", page.RespInfo.Hrefs)
+	}
+}
+
+func TestExtractPDF(t *testing.T) {
+	testUrl, _ := url.Parse("http://test.com/doc.pdf")
+	page := PageFromDataMime(testPDF("http://test.com/linked"), testUrl, "application/pdf", false)
+	if !ContainsString(page.RespInfo.Hrefs, "http://test.com/linked") {
+		t.Error("link annotation not found ", page.RespInfo.Hrefs)
+	}
+
+	page = PageFromDataMime([]byte("%PDF-1.4\nbroken"), testUrl, "application/pdf", false)
+	if len(page.RespInfo.Hrefs) != 0 {
+		t.Error("broken pdf returned links")
+	}
+}
+
+func TestRegisterExtractor(t *testing.T) {
+	RegisterExtractor("application/x-test", func(page *Page, baseUrl *url.URL) error {
+		page.RespInfo.Hrefs = []string{ToAbsUrl(baseUrl, string(page.ResponseBody))}
+		return nil
+	})
+	defer delete(extractors, "application/x-test")
+
+	testUrl, _ := url.Parse("http://test.com/")
+	page := PageFromDataMime([]byte("/custom"), testUrl, "application/x-test", false)
+	if len(page.RespInfo.Hrefs) != 1 || page.RespInfo.Hrefs[0] != "http://test.com/custom" {
+		t.Error("custom extractor not used ", page.RespInfo.Hrefs)
+	}
+}
+
+// testPDF builds a one page pdf with an uri link annotation
+func testPDF(uri string) []byte {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 200 200] /Annots [4 0 R] >>",
+		"<< /Type /Annot /Subtype /Link /Rect [0 0 100 20] /A << /S /URI /URI (" + uri + ") >> >>",
+	}
+	buf := &bytes.Buffer{}
+	buf.WriteString("%PDF-1.4\n")
+	offsets := []int{}
+	for i, obj := range objects {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+func TestExtractXHTML(t *testing.T) {
+	str := `<?xml version="1.0"?><html xmlns="http://www.w3.org/1999/xhtml"><head><title>x</title></head>
+	<body><a href="/1">1</a><form action="/search"><input name="q"/></form><script src="/app.js"></script></body></html>`
+	testUrl, _ := url.Parse("http://test.com/")
+	page := PageFromDataMime([]byte(str), testUrl, "application/xhtml+xml", false)
+	if len(page.RespInfo.Forms) != 1 || len(page.RespInfo.Ressources) == 0 || page.RespInfo.Meta == nil {
+		t.Error("xhtml not parsed as html ", page.RespInfo)
+	}
+}
@@ -0,0 +1,364 @@
+package crawlbase
+
+import (
+	"bytes"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FormFiller generates values for form inputs. Dictionary values by input
+// name take precedence over the default values by input type.
+type FormFiller struct {
+	Dictionary map[string]string
+	Defaults   map[string]string
+}
+
+var DefaultFormValues = map[string]string{
+	"text":           "test",
+	"search":         "test",
+	"textarea":       "test",
+	"email":          "test@example.com",
+	"password":       "Test1234!",
+	"number":         "1",
+	"range":          "1",
+	"tel":            "5555555555",
+	"url":            "http://example.com/",
+	"date":           "2000-01-01",
+	"month":          "2000-01",
+	"week":           "2000-W01",
+	"time":           "12:00",
+	"datetime-local": "2000-01-01T12:00",
+	"color":          "#000000",
+	"file":           "test.txt",
+}
+
+// forms matching this are never submitted
+var DestructiveFormPattern = regexp.MustCompile(`(?i)(delete|remove|destroy|drop|logout|log-out|log_out|signout|sign-out|sign_out|unsubscribe|deactivate|cancel)`)
+
+const (
+	EnctypeUrlEncoded = "application/x-www-form-urlencoded"
+	EnctypeMultipart  = "multipart/form-data"
+	EnctypeTextPlain  = "text/plain"
+)
+
+func NewFormFiller() *FormFiller {
+	ff := &FormFiller{Dictionary: map[string]string{}, Defaults: map[string]string{}}
+	for k, v := range DefaultFormValues {
+		ff.Defaults[k] = v
+	}
+	return ff
+}
+
+// Fill returns the values a browser submits when submitter is clicked,
+// submitter may be nil
+func (ff *FormFiller) Fill(form Form, submitter *FormInput) url.Values {
+	values := url.Values{}
+	checkedRadios := map[string]bool{}
+	for _, input := range form.Inputs {
+		if input.Type == "radio" && input.Checked {
+			checkedRadios[input.Name] = true
+		}
+	}
+
+	for _, input := range form.Inputs {
+		if input.Name == "" {
+			continue
+		}
+		inputType := strings.ToLower(input.Type)
+		if input.Tag == "textarea" {
+			inputType = "textarea"
+		}
+
+		switch {
+		case inputType == "submit" || inputType == "image" || inputType == "button" || inputType == "reset":
+			if submitter != nil && inputType != "reset" && input.Name == submitter.Name && input.Value == submitter.Value {
+				values.Add(input.Name, input.Value)
+			}
+		case inputType == "checkbox":
+			if input.Checked {
+				values.Add(input.Name, valueOrOn(input.Value))
+			}
+		case inputType == "radio":
+			// select the first radio of groups without a checked one
+			if input.Checked || !checkedRadios[input.Name] {
+				checkedRadios[input.Name] = true
+				values.Add(input.Name, valueOrOn(input.Value))
+			}
+		case inputType == "hidden":
+			values.Add(input.Name, input.Value)
+		case input.Tag == "select":
+			if v, ok := ff.Dictionary[input.Name]; ok {
+				values.Add(input.Name, v)
+			} else if input.Value != "" {
+				values.Add(input.Name, input.Value)
+			}
+		default:
+			values.Add(input.Name, ff.inputValue(input, inputType))
+		}
+	}
+	return values
+}
+
+func (ff *FormFiller) inputValue(input FormInput, inputType string) string {
+	value, ok := ff.Dictionary[input.Name]
+	if !ok {
+		value = input.Value
+	}
+	if value == "" {
+		value, ok = ff.Defaults[inputType]
+		if !ok {
+			value = ff.Defaults["text"]
+		}
+	}
+	if input.MaxLength > 0 && len(value) > input.MaxLength {
+		value = value[:input.MaxLength]
+	}
+	return value
+}
+
+func valueOrOn(value string) string {
+	if value == "" {
+		return "on"
+	}
+	return value
+}
+
+// IsDestructiveForm checks the form action, id, name and buttons for
+// actions like delete or logout
+func IsDestructiveForm(form Form) bool {
+	if strings.EqualFold(form.Method, "delete") {
+		return true
+	}
+	if DestructiveFormPattern.MatchString(form.Url + " " + form.Id + " " + form.Name) {
+		return true
+	}
+	for _, input := range form.Inputs {
+		if input.Type != "submit" && input.Type != "button" && input.Type != "image" {
+			continue
+		}
+		if DestructiveFormPattern.MatchString(input.Name + " " + input.Value + " " + input.FormAction) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewFormRequest encodes values according to the form method and enctype,
+// the form is submitted to pageUrl if it has no action
+func NewFormRequest(pageUrl string, form Form, values url.Values, submitter *FormInput) (*http.Request, []byte, error) {
+	action := form.Url
+	method := form.Method
+	enctype := form.Enctype
+	if submitter != nil {
+		if submitter.FormAction != "" {
+			action = submitter.FormAction
+		}
+		if submitter.FormMethod != "" {
+			method = submitter.FormMethod
+		}
+		if submitter.FormEnctype != "" {
+			enctype = submitter.FormEnctype
+		}
+	}
+	if action == "" {
+		action = pageUrl
+	}
+	method = strings.ToUpper(method)
+	if method != "POST" {
+		method = "GET"
+	}
+
+	actionUrl, err := url.Parse(action)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fields := formFields(form, values)
+	if method == "GET" {
+		actionUrl.RawQuery = encodeUrlEncoded(fields)
+		req, err := http.NewRequest(method, actionUrl.String(), nil)
+		return req, nil, err
+	}
+
+	body := []byte{}
+	contentType := EnctypeUrlEncoded
+	switch strings.ToLower(enctype) {
+	case EnctypeMultipart:
+		body, contentType, err = encodeMultipart(form, fields)
+		if err != nil {
+			return nil, nil, err
+		}
+	case EnctypeTextPlain:
+		buf := &bytes.Buffer{}
+		for _, f := range fields {
+			buf.WriteString(f.Name + "=" + f.Value + "\r\n")
+		}
+		body = buf.Bytes()
+		contentType = EnctypeTextPlain
+	default:
+		body = []byte(encodeUrlEncoded(fields))
+	}
+
+	req, err := http.NewRequest(method, actionUrl.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	return req, body, nil
+}
+
+type formField struct {
+	Name  string
+	Value string
+}
+
+// formFields orders values like a browser by the inputs of the form, values
+// without input follow sorted by name
+func formFields(form Form, values url.Values) []formField {
+	fields := []formField{}
+	added := map[string]bool{}
+	addName := func(name string) {
+		if added[name] {
+			return
+		}
+		added[name] = true
+		for _, v := range values[name] {
+			fields = append(fields, formField{name, v})
+		}
+	}
+	for _, input := range form.Inputs {
+		addName(input.Name)
+	}
+	names := []string{}
+	for name := range values {
+		if !added[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		addName(name)
+	}
+	return fields
+}
+
+func encodeUrlEncoded(fields []formField) string {
+	pairs := []string{}
+	for _, f := range fields {
+		pairs = append(pairs, url.QueryEscape(f.Name)+"="+url.QueryEscape(f.Value))
+	}
+	return strings.Join(pairs, "&")
+}
+
+func encodeMultipart(form Form, fields []formField) ([]byte, string, error) {
+	fileInputs := []string{}
+	for _, input := range form.Inputs {
+		if strings.ToLower(input.Type) == "file" {
+			fileInputs = append(fileInputs, input.Name)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	w := multipart.NewWriter(buf)
+	for _, f := range fields {
+		if ContainsString(fileInputs, f.Name) {
+			fw, err := w.CreateFormFile(f.Name, f.Value)
+			if err != nil {
+				return nil, "", err
+			}
+			fw.Write([]byte("test"))
+			continue
+		}
+		err := w.WriteField(f.Name, f.Value)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	err := w.Close()
+	if err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), w.FormDataContentType(), nil
+}
+
+// SubmitForm fills and submits a form found on pageUrl, with ScopeToDomain
+// the action has to be on the domain of pageUrl
+func (c *Crawler) SubmitForm(pageUrl string, form Form) (*Page, error) {
+	scopeUrl, err := url.Parse(pageUrl)
+	if err != nil {
+		return nil, err
+	}
+	return c.submitForm(pageUrl, form, scopeUrl)
+}
+
+func (c *Crawler) submitForm(pageUrl string, form Form, startUrl *url.URL) (*Page, error) {
+	ff := c.FormFiller
+	if ff == nil {
+		ff = NewFormFiller()
+	}
+
+	submitter := firstSubmitButton(form)
+	values := ff.Fill(form, submitter)
+	req, body, err := NewFormRequest(pageUrl, form, values, submitter)
+	if err != nil {
+		return nil, err
+	}
+	req.URL, err = c.CheckScope(req.URL.String(), startUrl)
+	if err != nil {
+		return nil, err
+	}
+	req.Host = req.URL.Host
+
+	page, err := c.DoRequest(req)
+	page.RequestBody = body
+	return page, err
+}
+
+func firstSubmitButton(form Form) *FormInput {
+	for i, input := range form.Inputs {
+		inputType := strings.ToLower(input.Type)
+		if inputType == "submit" || inputType == "image" {
+			return &form.Inputs[i]
+		}
+	}
+	return nil
+}
+
+func (cw *Crawler) submitPageForms(page *Page, startUrl *url.URL) {
+	if cw.submittedForms == nil {
+		cw.submittedForms = map[string]bool{}
+	}
+
+	for _, form := range page.RespInfo.Forms {
+		key := FormKey(form)
+		if cw.submittedForms[key] {
+			continue
+		}
+		cw.submittedForms[key] = true
+
+		if IsDestructiveForm(form) {
+			log.Println("skipping destructive form: ", key)
+			continue
+		}
+
+		time.Sleep(time.Duration(cw.WaitBetweenRequests) * time.Millisecond)
+
+		formPage, err := cw.submitForm(page.URL, form, startUrl)
+		if err == ErrorOutOfScope {
+			log.Println("skipping form out of scope: ", key)
+			continue
+		}
+		if formPage == nil {
+			log.Println("form submit error: ", err)
+			continue
+		}
+		log.Println("submitted form: "+key, formPage.Response.StatusCode, len(formPage.ResponseBody))
+		cw.processPage(formPage, err, startUrl)
+	}
+}
@@ -0,0 +1,161 @@
+package crawlbase
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestFormFillerFill(t *testing.T) {
+	form := Form{Inputs: []FormInput{
+		{Name: "user", Type: "text", Tag: "input"},
+		{Name: "mail", Type: "email", Tag: "input"},
+		{Name: "token", Type: "hidden", Tag: "input", Value: "abc"},
+		{Name: "size", Type: "radio", Tag: "input", Value: "s"},
+		{Name: "size", Type: "radio", Tag: "input", Value: "m"},
+		{Name: "news", Type: "checkbox", Tag: "input"},
+		{Name: "code", Type: "text", Tag: "input", MaxLength: 2},
+	}}
+
+	ff := NewFormFiller()
+	ff.Dictionary["user"] = "admin"
+	values := ff.Fill(form, nil)
+
+	if values.Get("user") != "admin" || values.Get("mail") != DefaultFormValues["email"] || values.Get("token") != "abc" {
+		t.Error("incorrect values: ", values)
+	}
+	if len(values["size"]) != 1 || values.Get("size") != "s" {
+		t.Error("incorrect radio value: ", values["size"])
+	}
+	if _, hasNews := values["news"]; hasNews {
+		t.Error("unchecked checkbox submitted")
+	}
+	if values.Get("code") != "te" {
+		t.Error("maxlength not respected")
+	}
+}
+
+func TestIsDestructiveForm(t *testing.T) {
+	if !IsDestructiveForm(Form{Url: "http://test.com/account/delete"}) {
+		t.Error("delete form not detected")
+	}
+	if !IsDestructiveForm(Form{Inputs: []FormInput{{Type: "submit", Value: "Logout"}}}) {
+		t.Error("logout button not detected")
+	}
+	if IsDestructiveForm(Form{Url: "http://test.com/search"}) {
+		t.Error("search form detected as destructive")
+	}
+}
+
+func TestSubmitFormMultipart(t *testing.T) {
+	received := ""
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseMultipartForm(1024)
+		received = r.Method + " " + r.FormValue("q")
+		if _, _, err := r.FormFile("upload"); err != nil {
+			received = "file missing"
+		}
+	}))
+	defer ts.Close()
+
+	form := Form{Method: "post", Enctype: EnctypeMultipart, Inputs: []FormInput{
+		{Name: "q", Type: "text", Tag: "input"},
+		{Name: "upload", Type: "file", Tag: "input"},
+	}}
+
+	cw := NewCrawler()
+	page, err := cw.SubmitForm(ts.URL, form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if received != "POST test" {
+		t.Error("incorrect submission: ", received)
+	}
+	if !strings.Contains(string(page.RequestBody), "test.txt") {
+		t.Error("request body not recorded")
+	}
+
+	folder, err := ioutil.TempDir("", "storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+	cw.StorageFolder = folder
+	cw.SavePage(page)
+	loaded, err := LoadPage(path.Join(folder, strconv.Itoa(page.CrawlTime)+".httpi"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(loaded.RequestBody, page.RequestBody) {
+		t.Error("request body not stored")
+	}
+}
+
+func TestSubmitPageFormsScope(t *testing.T) {
+	received := []string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Method+" "+r.URL.Path)
+	}))
+	defer ts.Close()
+
+	startUrl, _ := url.Parse(ts.URL)
+	page := &Page{URL: ts.URL}
+	page.RespInfo.Forms = []Form{
+		{Url: ts.URL + "/search", Method: "get", Inputs: []FormInput{{Name: "q", Type: "text"}}},
+		{Url: "http://other.test/collect", Method: "post", Inputs: []FormInput{{Name: "mail", Type: "email"}}},
+		{Url: "ftp://" + startUrl.Host + "/upload", Method: "post"},
+	}
+
+	cw := NewCrawler()
+	cw.StorageFolder = ""
+	cw.WaitBetweenRequests = 0
+	cw.ScopeToDomain = true
+	cw.submitPageForms(page, startUrl)
+	if len(received) != 1 || received[0] != "GET /search" {
+		t.Error("forms out of scope submitted: ", received)
+	}
+
+	cw = NewCrawler()
+	cw.BeforeCrawlFn = func(u string) (string, error) {
+		return strings.Replace(u, "/search", "/find", 1), nil
+	}
+	received = []string{}
+	_, err := cw.SubmitForm(ts.URL, page.RespInfo.Forms[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 1 || received[0] != "GET /find" {
+		t.Error("BeforeCrawlFn not applied: ", received)
+	}
+}
+
+func TestNewFormRequestFieldOrder(t *testing.T) {
+	form := Form{Method: "post", Inputs: []FormInput{
+		{Name: "z", Type: "text"},
+		{Name: "a", Type: "text"},
+		{Name: "m", Type: "text"},
+	}}
+	values := url.Values{"z": {"1"}, "a": {"2"}, "m": {"3"}, "extra": {"4"}}
+
+	for _, enctype := range []string{EnctypeUrlEncoded, EnctypeTextPlain} {
+		form.Enctype = enctype
+		_, body, err := NewFormRequest("http://test.com/", form, values, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := "z=1&a=2&m=3&extra=4"
+		if enctype == EnctypeTextPlain {
+			expected = "z=1\r\na=2\r\nm=3\r\nextra=4\r\n"
+		}
+		if string(body) != expected {
+			t.Error("fields not in input order: ", strconv.Quote(string(body)))
+		}
+	}
+}
@@ -0,0 +1,352 @@
+package crawlbase
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// HAR 1.2, see http://www.softwareishard.com/blog/har-12-spec/
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+}
+
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type HAREntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARCookie    `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARCookie    `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type HARCookie struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Path     string `json:"path,omitempty"`
+	Domain   string `json:"domain,omitempty"`
+	Expires  string `json:"expires,omitempty"`
+	HTTPOnly bool   `json:"httpOnly,omitempty"`
+	Secure   bool   `json:"secure,omitempty"`
+	SameSite string `json:"sameSite,omitempty"` // exported by chrome, not part of HAR 1.2
+}
+
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type HARContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type HARTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+func PagesToHAR(pages []*Page) *HAR {
+	har := &HAR{}
+	har.Log.Version = "1.2"
+	har.Log.Creator = HARCreator{Name: "crawlbase", Version: "1.0"}
+	har.Log.Entries = []HAREntry{}
+
+	for _, page := range pages {
+		har.Log.Entries = append(har.Log.Entries, PageToHAREntry(page))
+	}
+	return har
+}
+
+func PageToHAREntry(page *Page) HAREntry {
+	entry := HAREntry{}
+	entry.StartedDateTime = time.Unix(int64(page.CrawlTime), 0).Format(time.RFC3339)
+	entry.Time = float64(page.RespDuration)
+	// only the total duration is known, so it is accounted as wait
+	entry.Timings = HARTimings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1, Wait: float64(page.RespDuration)}
+	entry.Comment = page.Error
+
+	req := &entry.Request
+	req.URL = page.URL
+	req.Method = "GET"
+	req.HeadersSize = -1
+	req.BodySize = int64(len(page.RequestBody))
+	req.Headers = []HARNameValue{}
+	req.Cookies = []HARCookie{}
+	req.QueryString = []HARNameValue{}
+	if page.Request != nil {
+		if page.Request.Method != "" {
+			req.Method = page.Request.Method
+		}
+		req.HTTPVersion = page.Request.Proto
+		req.Headers = headerToHAR(page.Request.Header)
+		req.Cookies = cookiesToHAR(page.Request.Cookies)
+	}
+	if pUrl, err := url.Parse(page.URL); err == nil {
+		for k, values := range pUrl.Query() {
+			for _, v := range values {
+				req.QueryString = append(req.QueryString, HARNameValue{Name: k, Value: v})
+			}
+		}
+	}
+	if len(page.RequestBody) > 0 {
+		req.PostData = &HARPostData{Text: string(page.RequestBody)}
+		if page.Request != nil {
+			req.PostData.MimeType = page.Request.Header.Get("Content-Type")
+		}
+	}
+
+	res := &entry.Response
+	res.HeadersSize = -1
+	res.BodySize = int64(len(page.ResponseBody))
+	res.Headers = []HARNameValue{}
+	res.Cookies = []HARCookie{}
+	res.Content.Size = int64(len(page.ResponseBody))
+	if page.Response != nil {
+		res.Status = page.Response.StatusCode
+		res.StatusText = http.StatusText(page.Response.StatusCode)
+		res.HTTPVersion = page.Response.Proto
+		res.Headers = headerToHAR(page.Response.Header)
+		res.Cookies = cookiesToHAR(page.Response.Cookies)
+		res.Content.MimeType = page.Response.ContentMIME
+		res.RedirectURL = page.Response.Header.Get("Location")
+	}
+
+	if utf8.Valid(page.ResponseBody) {
+		res.Content.Text = string(page.ResponseBody)
+	} else {
+		res.Content.Text = base64.StdEncoding.EncodeToString(page.ResponseBody)
+		res.Content.Encoding = "base64"
+	}
+
+	return entry
+}
+
+func WriteHAR(w io.Writer, pages []*Page) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(PagesToHAR(pages))
+}
+
+// ExportHAR writes all pages of a storage folder accepted by filter to harFile
+func ExportHAR(folder, harFile string, filter func(*Page) bool) error {
+	pages, err := LoadAllPages(folder, true, filter)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(harFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return WriteHAR(f, pages)
+}
+
+func ReadHAR(r io.Reader) (*HAR, error) {
+	har := &HAR{}
+	err := json.NewDecoder(r).Decode(har)
+	if err != nil {
+		return nil, err
+	}
+	return har, nil
+}
+
+func PagesFromHAR(har *HAR, includeHiddenLinks bool) []*Page {
+	pages := []*Page{}
+	for _, entry := range har.Log.Entries {
+		page, err := PageFromHAREntry(entry, includeHiddenLinks)
+		if err != nil {
+			continue
+		}
+		pages = append(pages, page)
+	}
+	return pages
+}
+
+func PageFromHAREntry(entry HAREntry, includeHiddenLinks bool) (*Page, error) {
+	reqUrl, err := url.Parse(entry.Request.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	body := []byte(entry.Response.Content.Text)
+	if entry.Response.Content.Encoding == "base64" {
+		body, err = base64.StdEncoding.DecodeString(entry.Response.Content.Text)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	header := headerFromHAR(entry.Response.Headers)
+	contentMIME := strings.Split(entry.Response.Content.MimeType, ";")[0]
+	if contentMIME == "" {
+		contentMIME = GetContentMime(header)
+	}
+	page := PageFromDataMime(body, reqUrl, contentMIME, includeHiddenLinks)
+
+	page.Response.StatusCode = entry.Response.Status
+	page.Response.Proto = entry.Response.HTTPVersion
+	page.Response.Header = header
+	page.Response.ContentLength = entry.Response.Content.Size
+	page.Response.ContentMIME = contentMIME
+	page.Response.Cookies = cookiesFromHAR(entry.Response.Cookies)
+
+	page.Request.Method = entry.Request.Method
+	page.Request.Proto = entry.Request.HTTPVersion
+	page.Request.Header = headerFromHAR(entry.Request.Headers)
+	page.Request.Cookies = cookiesFromHAR(entry.Request.Cookies)
+	if entry.Request.PostData != nil {
+		page.RequestBody = []byte(entry.Request.PostData.Text)
+		page.Request.ContentLength = int64(len(page.RequestBody))
+	}
+
+	AddRedirectHrefs(page, reqUrl)
+
+	started, err := time.Parse(time.RFC3339Nano, entry.StartedDateTime)
+	if err == nil {
+		page.CrawlTime = int(started.Unix())
+	}
+	page.URL = reqUrl.String()
+	page.Uid = ToHash(page.URL)
+	page.RespDuration = int(entry.Time)
+	page.Error = entry.Comment
+
+	return page, nil
+}
+
+// ImportHAR reads a HAR file and adds its pages to the frontier
+func (cw *Crawler) ImportHAR(harFile string) ([]*Page, error) {
+	f, err := os.Open(harFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	har, err := ReadHAR(f)
+	if err != nil {
+		return nil, err
+	}
+
+	pages := PagesFromHAR(har, cw.IncludeHiddenLinks)
+	for _, page := range pages {
+		cw.AddPage(page)
+	}
+	return pages, nil
+}
+
+func headerToHAR(header http.Header) []HARNameValue {
+	keys := []string{}
+	for k := range header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	values := []HARNameValue{}
+	for _, k := range keys {
+		for _, v := range header[k] {
+			values = append(values, HARNameValue{Name: k, Value: v})
+		}
+	}
+	return values
+}
+
+func headerFromHAR(values []HARNameValue) http.Header {
+	header := http.Header{}
+	for _, v := range values {
+		// browsers export http2 pseudo headers like :authority
+		if strings.HasPrefix(v.Name, ":") {
+			continue
+		}
+		header.Add(v.Name, v.Value)
+	}
+	return header
+}
+
+func cookiesToHAR(cookies []Cookie) []HARCookie {
+	harCookies := []HARCookie{}
+	for _, c := range cookies {
+		harCookies = append(harCookies, HARCookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Path:     c.Path,
+			Domain:   c.Domain,
+			HTTPOnly: c.Httponly,
+			Secure:   c.Secure,
+			SameSite: c.SameSite,
+		})
+	}
+	return harCookies
+}
+
+func cookiesFromHAR(harCookies []HARCookie) []Cookie {
+	cookies := []Cookie{}
+	for _, c := range harCookies {
+		cookies = append(cookies, Cookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   c.Domain,
+			Httponly: c.HTTPOnly,
+			Path:     c.Path,
+			Secure:   c.Secure,
+			SameSite: c.SameSite,
+		})
+	}
+	return cookies
+}
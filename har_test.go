@@ -0,0 +1,66 @@
+package crawlbase
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestHARRoundTrip(t *testing.T) {
+	pUrl, _ := url.Parse("http://test.com/a?q=1")
+	p := PageFromData([]byte("<a href='/b'></a>"), pUrl, false)
+	p.URL = pUrl.String()
+	p.CrawlTime = 1500000000
+	p.Request.Method = "GET"
+	p.Response.StatusCode = 200
+	p.Response.ContentMIME = "text/html"
+	p.Response.Header = http.Header{}
+	p.Response.Header.Set("Server", "test")
+	p.Response.Cookies = []Cookie{{Name: "sid", Value: "1", Httponly: true}}
+
+	buf := &bytes.Buffer{}
+	err := WriteHAR(buf, []*Page{p})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	har, err := ReadHAR(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages := PagesFromHAR(har, false)
+	if len(pages) != 1 {
+		t.Fatal("incorrect page count")
+	}
+
+	imported := pages[0]
+	if imported.URL != p.URL || imported.CrawlTime != p.CrawlTime {
+		t.Error("url or crawl time not imported: ", imported.URL, imported.CrawlTime)
+	}
+	if imported.Response.Header.Get("Server") != "test" {
+		t.Error("header not imported")
+	}
+	if len(imported.Response.Cookies) != 1 || !imported.Response.Cookies[0].Httponly {
+		t.Error("cookie not imported")
+	}
+	if !ContainsString(imported.RespInfo.Hrefs, "http://test.com/b") {
+		t.Error("links not extracted: ", imported.RespInfo.Hrefs)
+	}
+}
+
+func TestHARBinaryBody(t *testing.T) {
+	p := &Page{URL: "http://test.com/img", ResponseBody: []byte{0xff, 0xd8, 0x00}}
+	entry := PageToHAREntry(p)
+	if entry.Response.Content.Encoding != "base64" {
+		t.Error("binary body not base64 encoded")
+	}
+
+	imported, err := PageFromHAREntry(entry, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(imported.ResponseBody, p.ResponseBody) {
+		t.Error("body not decoded")
+	}
+}
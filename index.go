@@ -0,0 +1,614 @@
+package crawlbase
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const indexFileName = "index.db"
+
+var indexBucket = []byte("pages")
+
+// secondary buckets keyed by field value and file, they narrow Find to the
+// entries of a host, status, mime type or cookie
+var indexFields = map[string]func(IndexEntry) []string{
+	"host":   func(e IndexEntry) []string { return []string{strings.ToLower(e.Host)} },
+	"status": func(e IndexEntry) []string { return []string{strconv.Itoa(e.StatusCode)} },
+	"mime":   func(e IndexEntry) []string { return []string{e.ContentMIME} },
+	"cookie": func(e IndexEntry) []string { return e.Cookies },
+}
+
+// body words are keyed by word and file in wordBucket, wordsBucket holds
+// every word seen and fileWordsBucket the words of each file to remove them.
+// Bodies which are not text are stored with an empty word, they are always
+// searched.
+var wordBucket = []byte("by-word")
+var wordsBucket = []byte("words")
+var fileWordsBucket = []byte("file-words")
+
+// PageIndex is an embedded index over the pages of a storage folder
+type PageIndex struct {
+	db *bolt.DB
+}
+
+type IndexEntry struct {
+	File        string
+	URL         string
+	Host        string
+	StatusCode  int
+	ContentMIME string
+	Headers     http.Header
+	Cookies     []string
+	FormActions []string
+	Ressources  []string
+}
+
+// PageQuery filters index entries, empty fields match everything.
+// FormAction, RessourceUrl, HeaderValue and BodyText match substrings.
+// Host, StatusCode, ContentMIME, CookieName and the words of BodyText are
+// looked up in the index, the other fields are checked on the entries found.
+// BodyText is then matched against the bodies of the entries found, which
+// are read from disk. A BodyText without letters or digits greps all stored
+// bodies.
+type PageQuery struct {
+	Host         string
+	StatusCode   int
+	ContentMIME  string
+	Header       string
+	HeaderValue  string
+	CookieName   string
+	FormAction   string
+	RessourceUrl string
+	BodyText     string
+}
+
+func OpenPageIndex(file string) (*PageIndex, error) {
+	db, err := bolt.Open(file, 0666, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		pages, err := tx.CreateBucketIfNotExists(indexBucket)
+		if err != nil {
+			return err
+		}
+		for field := range indexFields {
+			if tx.Bucket(fieldBucket(field)) != nil {
+				continue
+			}
+			_, err := tx.CreateBucket(fieldBucket(field))
+			if err != nil {
+				return err
+			}
+			// index created before the field existed
+			err = pages.ForEach(func(k, v []byte) error {
+				entry := IndexEntry{}
+				err := json.Unmarshal(v, &entry)
+				if err != nil {
+					return err
+				}
+				return putFieldKeys(tx, field, entry)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return createWordBuckets(tx)
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &PageIndex{db: db}, nil
+}
+
+// createWordBuckets adds the body words of an index created before words
+// were indexed, the bodies are loaded from disk
+func createWordBuckets(tx *bolt.Tx) error {
+	if tx.Bucket(wordBucket) != nil {
+		return nil
+	}
+	for _, name := range [][]byte{wordBucket, wordsBucket, fileWordsBucket} {
+		_, err := tx.CreateBucketIfNotExists(name)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Bucket(indexBucket).ForEach(func(k, v []byte) error {
+		words := []string{""}
+		page, err := LoadPage(string(k), true)
+		if err == nil {
+			words = pageWords(page)
+		}
+		return putWords(tx, string(k), words)
+	})
+}
+
+func (pi *PageIndex) Close() error {
+	return pi.db.Close()
+}
+
+func NewIndexEntry(file string, page *Page) IndexEntry {
+	entry := IndexEntry{File: file, URL: page.URL}
+	if pUrl, err := url.Parse(page.URL); err == nil {
+		entry.Host = pUrl.Host
+	}
+	if page.Response != nil {
+		entry.StatusCode = page.Response.StatusCode
+		entry.ContentMIME = page.Response.ContentMIME
+		entry.Headers = page.Response.Header
+		entry.Cookies = GetCookieNames(page.Response)
+	}
+	for _, form := range page.RespInfo.Forms {
+		entry.FormActions = append(entry.FormActions, form.Url)
+	}
+	for _, res := range page.RespInfo.Ressources {
+		entry.Ressources = append(entry.Ressources, res.Url)
+	}
+	return entry
+}
+
+// GetCookieNames returns the names of recorded and Set-Cookie cookies
+func GetCookieNames(res *PageResponse) []string {
+	names := []string{}
+	for _, c := range res.Cookies {
+		if !ContainsString(names, c.Name) {
+			names = append(names, c.Name)
+		}
+	}
+	httpRes := http.Response{Header: res.Header}
+	for _, c := range httpRes.Cookies() {
+		if !ContainsString(names, c.Name) {
+			names = append(names, c.Name)
+		}
+	}
+	return names
+}
+
+func fieldBucket(field string) []byte {
+	return []byte("by-" + field)
+}
+
+func fieldKey(value, file string) []byte {
+	return []byte(value + "\x00" + file)
+}
+
+func putFieldKeys(tx *bolt.Tx, field string, entry IndexEntry) error {
+	b := tx.Bucket(fieldBucket(field))
+	for _, value := range indexFields[field](entry) {
+		err := b.Put(fieldKey(value, entry.File), []byte{})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeEntry deletes the entry of file and its field keys
+func removeEntry(tx *bolt.Tx, file string) error {
+	pages := tx.Bucket(indexBucket)
+	content := pages.Get([]byte(file))
+	if content == nil {
+		return nil
+	}
+	entry := IndexEntry{}
+	err := json.Unmarshal(content, &entry)
+	if err != nil {
+		return err
+	}
+	for field, values := range indexFields {
+		b := tx.Bucket(fieldBucket(field))
+		for _, value := range values(entry) {
+			err := b.Delete(fieldKey(value, file))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	err = removeWords(tx, file)
+	if err != nil {
+		return err
+	}
+	return pages.Delete([]byte(file))
+}
+
+func isWordByte(ch byte) bool {
+	return ch >= 0x80 || (ch >= '0' && ch <= '9') || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+}
+
+// bodyWords returns the distinct words of body, ascii letters are lower
+// cased and other bytes above 0x7f are part of words
+func bodyWords(body []byte) []string {
+	words := []string{}
+	seen := map[string]bool{}
+	start := -1
+	for i := 0; i <= len(body); i++ {
+		if i < len(body) && isWordByte(body[i]) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			word := strings.ToLower(string(body[start:i]))
+			if !seen[word] {
+				seen[word] = true
+				words = append(words, word)
+			}
+			start = -1
+		}
+	}
+	return words
+}
+
+// pageWords returns the words to index for the body of page
+func pageWords(page *Page) []string {
+	if page.Response != nil && page.Response.ContentMIME != "" && !isTextMime(page.Response.ContentMIME) {
+		return []string{""}
+	}
+	return bodyWords(page.ResponseBody)
+}
+
+func putWords(tx *bolt.Tx, file string, words []string) error {
+	b := tx.Bucket(wordBucket)
+	vocabulary := tx.Bucket(wordsBucket)
+	for _, word := range words {
+		err := b.Put(fieldKey(word, file), []byte{})
+		if err != nil {
+			return err
+		}
+		if word == "" {
+			continue
+		}
+		err = vocabulary.Put([]byte(word), []byte{})
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Bucket(fileWordsBucket).Put([]byte(file), []byte(strings.Join(words, "\n")))
+}
+
+// removeWords deletes the word keys of file, words stay in the vocabulary
+func removeWords(tx *bolt.Tx, file string) error {
+	fileWords := tx.Bucket(fileWordsBucket)
+	content := fileWords.Get([]byte(file))
+	if content == nil {
+		return nil
+	}
+	b := tx.Bucket(wordBucket)
+	for _, word := range strings.Split(string(content), "\n") {
+		err := b.Delete(fieldKey(word, file))
+		if err != nil {
+			return err
+		}
+	}
+	return fileWords.Delete([]byte(file))
+}
+
+// wordFiles returns the files of the body words matching the word of a
+// query. A word at the start or end of the query may be the end or the
+// start of a body word.
+func wordFiles(tx *bolt.Tx, word string, prefixOnly, suffixOnly bool) map[string]bool {
+	b := tx.Bucket(wordBucket)
+	files := map[string]bool{}
+	addFiles := func(word string) {
+		prefix := fieldKey(word, "")
+		c := b.Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			files[string(k[len(prefix):])] = true
+		}
+	}
+	if !prefixOnly && !suffixOnly {
+		addFiles(word)
+		return files
+	}
+	tx.Bucket(wordsBucket).ForEach(func(k, v []byte) error {
+		w := string(k)
+		if (prefixOnly && !suffixOnly && strings.HasPrefix(w, word)) ||
+			(suffixOnly && !prefixOnly && strings.HasSuffix(w, word)) ||
+			(prefixOnly && suffixOnly && strings.Contains(w, word)) {
+			addFiles(w)
+		}
+		return nil
+	})
+	return files
+}
+
+// bodyTextFiles returns the files whose bodies may contain text, ok is
+// false if text has no words
+func bodyTextFiles(tx *bolt.Tx, text string) (files map[string]bool, ok bool) {
+	words := bodyWords([]byte(text))
+	if len(words) == 0 {
+		return nil, false
+	}
+	lower := strings.ToLower(text)
+	for i, word := range words {
+		// the first and last word may be cut off in the body
+		isFirst := i == 0 && strings.HasPrefix(lower, word)
+		isLast := i == len(words)-1 && strings.HasSuffix(lower, word)
+		found := wordFiles(tx, word, isLast, isFirst)
+		if files == nil {
+			files = found
+			continue
+		}
+		for file := range files {
+			if !found[file] {
+				delete(files, file)
+			}
+		}
+	}
+	// bodies which are not text
+	for file := range wordFiles(tx, "", false, false) {
+		files[file] = true
+	}
+	return files, true
+}
+
+// fieldFiles returns the files indexed with value for field
+func fieldFiles(tx *bolt.Tx, field, value string) map[string]bool {
+	files := map[string]bool{}
+	prefix := fieldKey(value, "")
+	c := tx.Bucket(fieldBucket(field)).Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		files[string(k[len(prefix):])] = true
+	}
+	return files
+}
+
+func (pi *PageIndex) Add(file string, page *Page) error {
+	entry := NewIndexEntry(file, page)
+	content, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return pi.db.Update(func(tx *bolt.Tx) error {
+		err := removeEntry(tx, file)
+		if err != nil {
+			return err
+		}
+		for field := range indexFields {
+			err = putFieldKeys(tx, field, entry)
+			if err != nil {
+				return err
+			}
+		}
+		err = putWords(tx, file, pageWords(page))
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(indexBucket).Put([]byte(file), content)
+	})
+}
+
+func (pi *PageIndex) Remove(file string) error {
+	return pi.db.Update(func(tx *bolt.Tx) error {
+		return removeEntry(tx, file)
+	})
+}
+
+func (pi *PageIndex) Count() int {
+	count := 0
+	pi.db.View(func(tx *bolt.Tx) error {
+		count = tx.Bucket(indexBucket).Stats().KeyN
+		return nil
+	})
+	return count
+}
+
+func (pi *PageIndex) Find(q PageQuery) ([]IndexEntry, error) {
+	entries := []IndexEntry{}
+	err := pi.db.View(func(tx *bolt.Tx) error {
+		pages := tx.Bucket(indexBucket)
+		match := func(k, v []byte) error {
+			entry := IndexEntry{}
+			err := json.Unmarshal(v, &entry)
+			if err != nil {
+				return err
+			}
+			if q.Match(entry) {
+				entries = append(entries, entry)
+			}
+			return nil
+		}
+
+		files, indexed := q.candidateFiles(tx)
+		if !indexed {
+			return pages.ForEach(match)
+		}
+		for _, file := range files {
+			v := pages.Get([]byte(file))
+			if v == nil {
+				continue
+			}
+			err := match([]byte(file), v)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if q.BodyText == "" {
+		return entries, nil
+	}
+
+	// the index narrows by words, the text is matched in the stored bodies
+	withText := []IndexEntry{}
+	for _, entry := range entries {
+		page, err := LoadPage(entry.File, true)
+		if err != nil {
+			continue
+		}
+		if bytes.Contains(page.ResponseBody, []byte(q.BodyText)) {
+			withText = append(withText, entry)
+		}
+	}
+	return withText, nil
+}
+
+// Query returns the stored pages matching q
+func (pi *PageIndex) Query(q PageQuery, withContent bool) ([]*Page, error) {
+	entries, err := pi.Find(q)
+	if err != nil {
+		return nil, err
+	}
+	pages := []*Page{}
+	for _, entry := range entries {
+		page, err := LoadPage(entry.File, withContent)
+		if err != nil {
+			return pages, err
+		}
+		pages = append(pages, page)
+	}
+	return pages, nil
+}
+
+// candidateFiles intersects the files of the indexed fields of q, indexed
+// is false if q has no indexed field
+func (q PageQuery) candidateFiles(tx *bolt.Tx) (files []string, indexed bool) {
+	values := map[string]string{}
+	if q.Host != "" {
+		values["host"] = strings.ToLower(q.Host)
+	}
+	if q.StatusCode != 0 {
+		values["status"] = strconv.Itoa(q.StatusCode)
+	}
+	if q.ContentMIME != "" {
+		values["mime"] = q.ContentMIME
+	}
+	if q.CookieName != "" {
+		values["cookie"] = q.CookieName
+	}
+
+	sets := []map[string]bool{}
+	for field, value := range values {
+		sets = append(sets, fieldFiles(tx, field, value))
+	}
+	if q.BodyText != "" {
+		if found, ok := bodyTextFiles(tx, q.BodyText); ok {
+			sets = append(sets, found)
+		}
+	}
+	if len(sets) == 0 {
+		return nil, false
+	}
+
+	candidates := sets[0]
+	for _, found := range sets[1:] {
+		for file := range candidates {
+			if !found[file] {
+				delete(candidates, file)
+			}
+		}
+	}
+	files = []string{}
+	for file := range candidates {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	return files, true
+}
+
+// Match checks all fields of q except BodyText
+func (q PageQuery) Match(entry IndexEntry) bool {
+	if q.Host != "" && !strings.EqualFold(q.Host, entry.Host) {
+		return false
+	}
+	if q.StatusCode != 0 && q.StatusCode != entry.StatusCode {
+		return false
+	}
+	if q.ContentMIME != "" && q.ContentMIME != entry.ContentMIME {
+		return false
+	}
+	if q.Header != "" {
+		values, hasHeader := entry.Headers[http.CanonicalHeaderKey(q.Header)]
+		if !hasHeader || !containsSubstring(values, q.HeaderValue) {
+			return false
+		}
+	}
+	if q.CookieName != "" && !ContainsString(entry.Cookies, q.CookieName) {
+		return false
+	}
+	if q.FormAction != "" && !containsSubstring(entry.FormActions, q.FormAction) {
+		return false
+	}
+	if q.RessourceUrl != "" && !containsSubstring(entry.Ressources, q.RessourceUrl) {
+		return false
+	}
+	return true
+}
+
+func containsSubstring(arr []string, substr string) bool {
+	for _, x := range arr {
+		if strings.Contains(x, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// OpenFolderIndex opens the index of an existing storage folder
+func OpenFolderIndex(folder string) (*PageIndex, error) {
+	return OpenPageIndex(path.Join(folder, indexFileName))
+}
+
+// OpenIndex opens the index inside the storage folder, saved pages are
+// added to it
+func (c *Crawler) OpenIndex() error {
+	err := os.MkdirAll(c.StorageFolder, 0777)
+	if err != nil {
+		return err
+	}
+	index, err := OpenFolderIndex(c.StorageFolder)
+	if err != nil {
+		return err
+	}
+	c.Index = index
+	return nil
+}
+
+// ReindexFolder rebuilds the index of an existing storage folder
+func ReindexFolder(folder string) (int, error) {
+	files, err := GetPageInfoFiles(folder)
+	if err != nil {
+		return 0, err
+	}
+
+	indexFile := path.Join(folder, indexFileName)
+	err = os.Remove(indexFile)
+	if err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+
+	index, err := OpenPageIndex(indexFile)
+	if err != nil {
+		return 0, err
+	}
+	defer index.Close()
+
+	count := 0
+	for _, file := range files {
+		page, err := LoadPage(file, true)
+		if err != nil {
+			return count, err
+		}
+		err = index.Add(file, page)
+		if err != nil {
+			return count, err
+		}
+		count += 1
+	}
+	return count, nil
+}
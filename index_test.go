@@ -0,0 +1,156 @@
+package crawlbase
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestPageIndexQuery(t *testing.T) {
+	folder, err := ioutil.TempDir("", "storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+
+	cw := NewCrawler()
+	cw.StorageFolder = folder
+	err = cw.OpenIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cw.Index.Close()
+
+	pUrl, _ := url.Parse("http://test.com/login")
+	p := PageFromData([]byte("<form action='/api/login'></form> welcome"), pUrl, false)
+	p.URL = pUrl.String()
+	p.CrawlTime = 1
+	p.Response.StatusCode = 200
+	p.Response.Header = http.Header{}
+	p.Response.Header.Add("Set-Cookie", "sid=1; HttpOnly")
+	cw.SavePage(p)
+
+	p2 := PageFromData([]byte("other"), pUrl, false)
+	p2.URL = "http://other.com/"
+	p2.CrawlTime = 2
+	p2.Response.StatusCode = 404
+	cw.SavePage(p2)
+
+	queries := map[string]PageQuery{
+		"cookie": {CookieName: "sid"},
+		"form":   {FormAction: "/api"},
+		"host":   {Host: "test.com", StatusCode: 200},
+		"text":   {BodyText: "welcome"},
+	}
+	for name, q := range queries {
+		entries, err := cw.Index.Find(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 || entries[0].URL != p.URL {
+			t.Error("query ", name, " incorrect result: ", entries)
+		}
+	}
+
+	if cw.Index.Count() != 2 {
+		t.Error("incorrect index count")
+	}
+
+	entries, err := cw.Index.Find(PageQuery{StatusCode: 404})
+	if err != nil || len(entries) != 1 || entries[0].URL != p2.URL {
+		t.Error("status query incorrect result: ", entries, err)
+	}
+	err = cw.Index.Remove(entries[0].File)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cw.Index.db.View(func(tx *bolt.Tx) error {
+		if len(fieldFiles(tx, "status", "404")) != 0 || len(fieldFiles(tx, "host", "other.com")) != 0 {
+			t.Error("field keys of removed entry kept")
+		}
+		return nil
+	})
+	entries, _ = cw.Index.Find(PageQuery{Host: "TEST.com", CookieName: "sid"})
+	if len(entries) != 1 {
+		t.Error("combined query incorrect result: ", entries)
+	}
+}
+
+func TestPageIndexBodyWords(t *testing.T) {
+	folder, err := ioutil.TempDir("", "storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+
+	cw := NewCrawler()
+	cw.StorageFolder = folder
+	err = cw.OpenIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cw.Index.Close()
+
+	pages := []struct {
+		Body, Mime string
+	}{
+		{"<p>Admin Panel login</p>", "text/html"},
+		{"<p>user settings</p>", "text/html"},
+		{"binary admin panel", "image/png"},
+	}
+	for i, p := range pages {
+		page := PageFromData([]byte(p.Body), nil, false)
+		page.URL = "http://test.com/" + strconv.Itoa(i)
+		page.CrawlTime = i + 1
+		page.Response.ContentMIME = p.Mime
+		cw.SavePage(page)
+	}
+
+	cw.Index.db.View(func(tx *bolt.Tx) error {
+		files, ok := bodyTextFiles(tx, "Admin Panel")
+		if !ok || len(files) != 2 {
+			t.Error("incorrect candidates: ", files)
+		}
+		files, _ = bodyTextFiles(tx, "settings")
+		if len(files) != 2 {
+			t.Error("non text body not searched: ", files)
+		}
+		return nil
+	})
+
+	queries := map[string]int{
+		"Admin Panel": 1,
+		"min Pan":     1,
+		"dmi":         2,
+		"in Panel lo": 1,
+		"admin panel": 1,
+		"</p>":        2,
+		"missing":     0,
+	}
+	for text, count := range queries {
+		entries, err := cw.Index.Find(PageQuery{BodyText: text})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != count {
+			t.Error("query ", text, " incorrect result: ", entries)
+		}
+	}
+
+	err = cw.Index.Remove(path.Join(folder, "1.httpi"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cw.Index.db.View(func(tx *bolt.Tx) error {
+		if files := wordFiles(tx, "admin", false, false); len(files) != 0 {
+			t.Error("words of removed entry kept: ", files)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,140 @@
+package crawlbase
+
+import (
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+type jsEndpointPattern struct {
+	Type  string
+	Regex *regexp.Regexp
+	Group int // submatch holding the url
+}
+
+var jsEndpointPatterns = []jsEndpointPattern{
+	{"fetch", regexp.MustCompile(`\bfetch\(\s*["'\x60]([^"'\x60]+)`), 1},
+	{"xhr", regexp.MustCompile(`\.open\(\s*["'](?i:GET|POST|PUT|DELETE|PATCH|HEAD|OPTIONS)["']\s*,\s*["'\x60]([^"'\x60]+)`), 1},
+	{"axios", regexp.MustCompile(`\baxios(?:\.(?:get|post|put|delete|patch|head|options|request))?\(\s*["'\x60]([^"'\x60]+)`), 1},
+	{"jquery", regexp.MustCompile(`\$\.(?:ajax|get|post|getJSON)\(\s*["'\x60]([^"'\x60]+)`), 1},
+	{"websocket", regexp.MustCompile(`\bnew\s+WebSocket\(\s*["'\x60]([^"'\x60]+)`), 1},
+	{"websocket", regexp.MustCompile(`["'\x60](wss?://[^"'\x60\s]+)`), 1},
+	{"sourcemap", regexp.MustCompile(`[#@]\s*sourceMappingURL=([^\s*'"]+)`), 1},
+}
+
+var regFindGraphQLOperation *regexp.Regexp = regexp.MustCompile(`\b(query|mutation|subscription)\s+([A-Za-z_][A-Za-z0-9_]*)\s*[({]`)
+
+// ExtractJSEndpoints finds api calls, urls, websocket and source map
+// references and graphql operations in a script. Request urls are resolved
+// against the page, source maps against the script.
+func ExtractJSEndpoints(code []byte, scriptUrl *url.URL, pageUrl *url.URL) []Ressource {
+	text := string(code)
+	source := ""
+	if scriptUrl != nil {
+		source = scriptUrl.String()
+	}
+
+	endpoints := []Ressource{}
+	seen := map[string]bool{}
+	add := func(res Ressource) {
+		key := res.Type + " " + res.Url + " " + res.Rel
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		endpoints = append(endpoints, res)
+	}
+
+	for _, p := range jsEndpointPatterns {
+		for _, m := range p.Regex.FindAllStringSubmatch(text, -1) {
+			rawUrl := m[p.Group]
+			// cut template literals at the first placeholder
+			if i := strings.Index(rawUrl, "${"); i >= 0 {
+				rawUrl = rawUrl[:i]
+			}
+			if rawUrl == "" || strings.HasPrefix(rawUrl, "data:") {
+				continue
+			}
+			base := pageUrl
+			if p.Type == "sourcemap" {
+				base = scriptUrl
+			}
+			add(Ressource{Url: ToAbsUrl(base, rawUrl), Type: p.Type, Tag: "js-endpoint", Source: source})
+		}
+	}
+
+	for _, m := range regFindGraphQLOperation.FindAllStringSubmatch(text, -1) {
+		add(Ressource{Type: "graphql-" + m[1], Rel: m[2], Tag: "js-endpoint", Source: source})
+	}
+
+	for _, u := range GetUrlsFromJS(text, pageUrl) {
+		add(Ressource{Url: u, Type: "string", Tag: "js-endpoint", Source: source})
+	}
+	return endpoints
+}
+
+// FetchScriptEndpoints downloads the scripts of a page and adds the found
+// endpoints to RespInfo.Requests
+func (c *Crawler) FetchScriptEndpoints(page *Page) {
+	if c.scriptEndpoints == nil {
+		c.scriptEndpoints = map[string][]Ressource{}
+	}
+	pageUrl, err := url.Parse(page.URL)
+	if err != nil {
+		return
+	}
+
+	for _, res := range page.RespInfo.Ressources {
+		if res.Tag != "script" || res.Url == "" {
+			continue
+		}
+
+		endpoints, fetched := c.scriptEndpoints[res.Url]
+		if !fetched {
+			scriptUrl, err := url.Parse(res.Url)
+			if err != nil || !c.IsValidScheme(scriptUrl) {
+				continue
+			}
+			scriptPage, err := c.GetPage(res.Url, "GET")
+			if err != nil || scriptPage == nil {
+				log.Println("FetchScriptEndpoints ", err)
+				continue
+			}
+			if c.ExecuteJS {
+				c.cacheScript(res.Url, scriptPage)
+			}
+			endpoints = ExtractJSEndpoints(scriptPage.ResponseBody, scriptUrl, pageUrl)
+			if c.ScanSecrets {
+				c.addSecrets(c.secretScanner().ScanPage(scriptPage))
+			}
+
+			mapUrl := sourceMapUrl(scriptPage, endpoints, scriptUrl)
+			if c.FetchSourceMaps && mapUrl != "" {
+				found, err := c.FetchSourceMap(mapUrl, scriptUrl, pageUrl)
+				if err != nil {
+					log.Println("FetchScriptEndpoints ", err)
+				}
+				endpoints = append(endpoints, found...)
+			}
+			c.scriptEndpoints[res.Url] = endpoints
+		}
+
+		for _, endpoint := range endpoints {
+			if endpoint.Tag == "sourcemap" {
+				page.RespInfo.Ressources = append(page.RespInfo.Ressources, endpoint)
+			} else if !containsRessource(page.RespInfo.Requests, endpoint) {
+				page.RespInfo.Requests = append(page.RespInfo.Requests, endpoint)
+			}
+		}
+	}
+}
+
+func containsRessource(ressources []Ressource, res Ressource) bool {
+	for _, r := range ressources {
+		if r.Url == res.Url && r.Type == res.Type && r.Rel == res.Rel && r.Source == res.Source {
+			return true
+		}
+	}
+	return false
+}
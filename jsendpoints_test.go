@@ -0,0 +1,66 @@
+package crawlbase
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestExtractJSEndpoints(t *testing.T) {
+	code := `fetch("/api/items").then(r => r.json());
+	xhr.open("POST", "/api/save");
+	axios.get(` + "`/api/users/${id}`" + `);
+	var ws = new WebSocket("wss://live.test.com/socket");
+	const q = gql` + "`query GetUser($id: ID!) { user(id: $id) { name } }`" + `;
+	//# sourceMappingURL=app.js.map`
+	scriptUrl, _ := url.Parse("http://cdn.test.com/js/app.js")
+	pageUrl, _ := url.Parse("http://test.com/")
+
+	found := map[string]string{}
+	for _, res := range ExtractJSEndpoints([]byte(code), scriptUrl, pageUrl) {
+		if res.Source != scriptUrl.String() {
+			t.Error("source script missing: ", res)
+		}
+		found[res.Type+" "+res.Url+res.Rel] = res.Url
+	}
+
+	expected := []string{
+		"fetch http://test.com/api/items",
+		"xhr http://test.com/api/save",
+		"axios http://test.com/api/users/",
+		"websocket wss://live.test.com/socket",
+		"graphql-query GetUser",
+		"sourcemap http://cdn.test.com/js/app.js.map",
+	}
+	for _, key := range expected {
+		if _, ok := found[key]; !ok {
+			t.Error("missing endpoint ", key, " in ", found)
+		}
+	}
+}
+
+func TestLinksFromPageSkipsApiCalls(t *testing.T) {
+	code := `x.open("DELETE", "/api/account");
+	axios.post("/api/transfer");
+	var ws = new WebSocket("wss://live.test.com/socket");
+	var about = "/about/team";
+	var out = "/account/logout";`
+	pageUrl, _ := url.Parse("http://test.com/")
+
+	page := &Page{}
+	page.RespInfo.Hrefs = []string{"http://test.com/home"}
+	page.RespInfo.Requests = ExtractJSEndpoints([]byte(code), pageUrl, pageUrl)
+
+	links := LinksFromPage(page)
+	expected := []string{"http://test.com/home", "http://test.com/about/team"}
+	if len(links) != len(expected) {
+		t.Fatal("wrong links ", links)
+	}
+	for i, link := range expected {
+		if links[i] != link {
+			t.Error("expected ", link, " got ", links[i])
+		}
+	}
+	if len(page.RespInfo.Requests) < 5 {
+		t.Error("endpoints missing from requests ", page.RespInfo.Requests)
+	}
+}
@@ -0,0 +1,392 @@
+package crawlbase
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"net/url"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/dop251/goja"
+)
+
+var ErrorJSMemoryLimit = errors.New("javascript memory limit exceeded")
+var ErrorJSTimeout = errors.New("javascript time limit exceeded")
+
+// JSSandbox executes page scripts with a minimal window, document,
+// location and XMLHttpRequest shim and records the urls they use
+type JSSandbox struct {
+	Timeout      time.Duration // for all scripts of a page
+	MaxMemory    uint64        // heap growth in bytes, sampled while running
+	MaxCallStack int
+	MaxWriteSize int // bytes of html written with document.write and innerHTML
+}
+
+type JSResult struct {
+	Navigations []string
+	Requests    []Ressource
+	Written     string
+	Errors      []string
+}
+
+// executed before the page scripts, the __ functions are bound to go
+const jsShim = `
+(function(global) {
+	function element(tag) {
+		var attrs = {};
+		var el = {
+			tagName: String(tag).toUpperCase(), style: {}, children: [], dataset: {}, classList: { add: function(){}, remove: function(){}, contains: function(){ return false; } },
+			setAttribute: function(name, value) {
+				name = String(name).toLowerCase();
+				attrs[name] = String(value);
+				if (name == "src" || name == "href" || name == "action" || name == "data") { __request(tag, String(value)); }
+			},
+			getAttribute: function(name) { return attrs[String(name).toLowerCase()] || null; },
+			appendChild: function(c) { this.children.push(c); return c; },
+			insertBefore: function(c) { this.children.push(c); return c; },
+			removeChild: function(c) { return c; },
+			addEventListener: function() {}, removeEventListener: function() {},
+			querySelector: function() { return element("div"); },
+			querySelectorAll: function() { return []; },
+			getElementsByTagName: function() { return []; },
+			submit: function() { if (attrs.action) { __navigate(attrs.action); } },
+			click: function() { if (attrs.href) { __navigate(attrs.href); } }
+		};
+		["src", "href", "action", "data"].forEach(function(name) {
+			Object.defineProperty(el, name, {
+				get: function() { return attrs[name] || ""; },
+				set: function(value) { el.setAttribute(name, value); }
+			});
+		});
+		Object.defineProperty(el, "innerHTML", {
+			get: function() { return ""; },
+			set: function(value) { __write(String(value)); }
+		});
+		return el;
+	}
+
+	var location = {
+		assign: function(u) { __navigate(String(u)); },
+		replace: function(u) { __navigate(String(u)); },
+		reload: function() {},
+		toString: function() { return __pageUrl; }
+	};
+	Object.defineProperty(location, "href", {
+		get: function() { return __pageUrl; },
+		set: function(u) { __navigate(String(u)); }
+	});
+	["protocol", "host", "hostname", "port", "pathname", "search", "hash", "origin"].forEach(function(name) {
+		location[name] = __pageLocation[name];
+	});
+
+	var document = element("document");
+	document.write = function() { __write(Array.prototype.join.call(arguments, "")); };
+	document.writeln = function() { __write(Array.prototype.join.call(arguments, "") + "\n"); };
+	document.createElement = function(tag) { return element(tag); };
+	document.createTextNode = function() { return element("#text"); };
+	document.getElementById = function() { return element("div"); };
+	document.getElementsByClassName = function() { return []; };
+	document.body = element("body");
+	document.head = element("head");
+	document.documentElement = element("html");
+	document.cookie = "";
+	document.referrer = "";
+	Object.defineProperty(document, "location", {
+		get: function() { return location; },
+		set: function(u) { __navigate(String(u)); }
+	});
+
+	function XMLHttpRequest() {}
+	XMLHttpRequest.prototype.open = function(method, u) { __request("xhr", String(u)); };
+	XMLHttpRequest.prototype.send = function() {};
+	XMLHttpRequest.prototype.setRequestHeader = function() {};
+	XMLHttpRequest.prototype.addEventListener = function() {};
+
+	function Image() { return element("img"); }
+
+	var response = { ok: true, status: 200, json: function() { return Promise.resolve({}); }, text: function() { return Promise.resolve(""); } };
+
+	global.window = global;
+	global.self = global;
+	global.top = global;
+	global.parent = global;
+	global.document = document;
+	global.XMLHttpRequest = XMLHttpRequest;
+	global.Image = Image;
+	global.navigator = { userAgent: __userAgent, language: "en-US", languages: ["en-US"], cookieEnabled: true };
+	global.console = { log: function() {}, warn: function() {}, error: function() {}, info: function() {}, debug: function() {} };
+	global.fetch = function(u) { __request("fetch", String(u && u.url ? u.url : u)); return Promise.resolve(response); };
+	global.open = function(u) { if (u) { __navigate(String(u)); } };
+	global.setTimeout = function(fn) { if (typeof fn == "function") { __defer(fn); } return 0; };
+	global.setInterval = global.setTimeout;
+	global.clearTimeout = function() {};
+	global.clearInterval = function() {};
+	global.addEventListener = function() {};
+	global.localStorage = global.sessionStorage = { getItem: function() { return null; }, setItem: function() {}, removeItem: function() {} };
+	Object.defineProperty(global, "location", {
+		get: function() { return location; },
+		set: function(u) { __navigate(String(u)); }
+	});
+})(this);
+`
+
+func NewJSSandbox() *JSSandbox {
+	return &JSSandbox{
+		Timeout:      2 * time.Second,
+		MaxMemory:    64 * 1024 * 1024,
+		MaxCallStack: 1024,
+		MaxWriteSize: 1024 * 1024,
+	}
+}
+
+// Run executes each script independently, errors of one script do not stop
+// the following ones
+func (sb *JSSandbox) Run(scripts []string, pageUrl *url.URL) *JSResult {
+	result := &JSResult{Navigations: []string{}, Requests: []Ressource{}, Errors: []string{}}
+	written := &bytes.Buffer{}
+	deferred := []goja.Callable{}
+
+	vm := goja.New()
+	vm.SetMaxCallStackSize(sb.MaxCallStack)
+
+	resolve := func(rawUrl string) string {
+		return ToAbsUrl(pageUrl, strings.TrimSpace(rawUrl))
+	}
+	vm.Set("__navigate", func(rawUrl string) {
+		u := resolve(rawUrl)
+		if u != "" && !ContainsString(result.Navigations, u) {
+			result.Navigations = append(result.Navigations, u)
+		}
+	})
+	vm.Set("__request", func(kind string, rawUrl string) {
+		u := resolve(rawUrl)
+		res := Ressource{Url: u, Type: strings.ToLower(kind), Tag: "js-exec"}
+		if u != "" && !containsRessource(result.Requests, res) {
+			result.Requests = append(result.Requests, res)
+		}
+	})
+	vm.Set("__write", func(html string) {
+		if written.Len()+len(html) > sb.MaxWriteSize {
+			return
+		}
+		written.WriteString(html)
+	})
+	vm.Set("__defer", func(fn goja.Value) {
+		if call, ok := goja.AssertFunction(fn); ok {
+			deferred = append(deferred, call)
+		}
+	})
+	vm.Set("__pageUrl", pageUrl.String())
+	vm.Set("__pageLocation", map[string]string{
+		"protocol": pageUrl.Scheme + ":",
+		"host":     pageUrl.Host,
+		"hostname": pageUrl.Hostname(),
+		"port":     pageUrl.Port(),
+		"pathname": pageUrl.EscapedPath(),
+		"search":   queryWithMark(pageUrl.RawQuery),
+		"hash":     "",
+		"origin":   pageUrl.Scheme + "://" + pageUrl.Host,
+	})
+	vm.Set("__userAgent", headerUserAgentChrome)
+
+	stop := sb.watch(vm)
+	defer close(stop)
+
+	_, err := vm.RunString(jsShim)
+	if err != nil {
+		result.Errors = append(result.Errors, err.Error())
+		return result
+	}
+
+	for _, script := range scripts {
+		_, err := vm.RunString(script)
+		if err != nil {
+			result.Errors = append(result.Errors, err.Error())
+			if _, interrupted := err.(*goja.InterruptedError); interrupted {
+				break
+			}
+		}
+	}
+	// timers run once after all scripts, new timers are not run again
+	for _, fn := range deferred {
+		_, err := fn(goja.Undefined())
+		if err != nil {
+			result.Errors = append(result.Errors, err.Error())
+			if _, interrupted := err.(*goja.InterruptedError); interrupted {
+				break
+			}
+		}
+	}
+
+	result.Written = written.String()
+	return result
+}
+
+// watch interrupts vm on timeout or when the heap grows more than
+// MaxMemory. The heap is process wide, so the memory limit is approximate.
+func (sb *JSSandbox) watch(vm *goja.Runtime) chan struct{} {
+	stop := make(chan struct{})
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	startHeap := mem.HeapAlloc
+
+	go func() {
+		timeout := time.After(sb.Timeout)
+		ticker := time.NewTicker(50 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-timeout:
+				vm.Interrupt(ErrorJSTimeout)
+				return
+			case <-ticker.C:
+				if sb.MaxMemory == 0 {
+					continue
+				}
+				runtime.ReadMemStats(&mem)
+				if mem.HeapAlloc > startHeap && mem.HeapAlloc-startHeap > sb.MaxMemory {
+					vm.Interrupt(ErrorJSMemoryLimit)
+					return
+				}
+			}
+		}
+	}()
+	return stop
+}
+
+func queryWithMark(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	return "?" + rawQuery
+}
+
+// MergeJSResult adds the urls of executed scripts and the links of written
+// html to the page
+func MergeJSResult(page *Page, result *JSResult, baseUrl *url.URL) {
+	for _, u := range result.Navigations {
+		if !ContainsString(page.RespInfo.Hrefs, u) {
+			page.RespInfo.Hrefs = append(page.RespInfo.Hrefs, u)
+		}
+	}
+	for _, req := range result.Requests {
+		if !containsRessource(page.RespInfo.Requests, req) {
+			page.RespInfo.Requests = append(page.RespInfo.Requests, req)
+		}
+	}
+
+	if result.Written == "" {
+		return
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(result.Written))
+	if err != nil {
+		return
+	}
+	for _, u := range GetHrefs(doc, baseUrl, false) {
+		if !ContainsString(page.RespInfo.Hrefs, u) {
+			page.RespInfo.Hrefs = append(page.RespInfo.Hrefs, u)
+		}
+	}
+	for _, res := range GetRessources(doc, baseUrl) {
+		res.Source = "document.write"
+		page.RespInfo.Ressources = append(page.RespInfo.Ressources, res)
+	}
+}
+
+// ExecutePageScripts runs the inline and external scripts of a page in
+// document order, then its event handlers and javascript: urls
+func (c *Crawler) ExecutePageScripts(page *Page) {
+	pageUrl, err := url.Parse(page.URL)
+	if err != nil {
+		return
+	}
+
+	baseUrl := pageUrl
+	scripts := []string{}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(page.ResponseBody))
+	if err == nil {
+		baseUrl = GetBaseUrl(doc, pageUrl)
+		doc.Find("script").Each(func(i int, s *goquery.Selection) {
+			scriptType, _ := s.Attr("type")
+			if !ContainsString(jsScriptTypes, strings.ToLower(strings.TrimSpace(scriptType))) {
+				return
+			}
+			src, hasSrc := s.Attr("src")
+			if !hasSrc {
+				if code := strings.TrimSpace(s.Text()); code != "" {
+					scripts = append(scripts, code)
+				}
+				return
+			}
+			body, err := c.scriptBody(ToAbsUrl(baseUrl, strings.TrimSpace(src)))
+			if err != nil {
+				log.Println("ExecutePageScripts ", err)
+				return
+			}
+			scripts = append(scripts, string(body))
+		})
+	}
+
+	for _, info := range page.RespInfo.JSInfo {
+		if (info.Source == "script" && doc == nil) || strings.HasPrefix(info.Source, "event:") || strings.HasPrefix(info.Source, "url:") {
+			scripts = append(scripts, info.Value)
+		}
+	}
+	if len(scripts) == 0 {
+		return
+	}
+
+	sb := c.JSSandbox
+	if sb == nil {
+		sb = NewJSSandbox()
+	}
+	result := sb.Run(scripts, pageUrl)
+	for _, e := range result.Errors {
+		log.Println("ExecutePageScripts ", page.URL, e)
+	}
+	MergeJSResult(page, result, baseUrl)
+}
+
+// scriptBody returns an external script, scripts are fetched once per crawl
+func (c *Crawler) scriptBody(scriptUrl string) ([]byte, error) {
+	if body, ok := c.scriptBodies[scriptUrl]; ok {
+		return body, nil
+	}
+	if c.scriptBodies == nil {
+		c.scriptBodies = map[string][]byte{}
+	}
+	u, err := url.Parse(scriptUrl)
+	if err != nil {
+		return nil, err
+	}
+	if !c.IsValidScheme(u) {
+		return nil, ErrorOutOfScope
+	}
+
+	scriptPage, err := c.GetPage(scriptUrl, "GET")
+	if err != nil {
+		// failed fetches are not retried
+		c.scriptBodies[scriptUrl] = nil
+		return nil, err
+	}
+	return c.cacheScript(scriptUrl, scriptPage), nil
+}
+
+// cacheScript keeps the body of a fetched script, error pages are cached
+// empty
+func (c *Crawler) cacheScript(scriptUrl string, scriptPage *Page) []byte {
+	if c.scriptBodies == nil {
+		c.scriptBodies = map[string][]byte{}
+	}
+	var body []byte
+	if scriptPage.Response == nil || scriptPage.Response.StatusCode < 400 {
+		body = scriptPage.ResponseBody
+	}
+	c.scriptBodies[scriptUrl] = body
+	return body
+}
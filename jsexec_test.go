@@ -0,0 +1,83 @@
+package crawlbase
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestJSSandboxRun(t *testing.T) {
+	scripts := []string{
+		`document.write('<a href="/written">w</a>');`,
+		`var x = new XMLHttpRequest(); x.open("GET", "/api/data"); x.send();`,
+		`undefinedFunction();`,
+		`setTimeout(function() { window.location = "/later"; }, 100);`,
+		`var s = document.createElement("script"); s.src = "//cdn.test.com/lib.js"; document.head.appendChild(s);`,
+	}
+	pageUrl, _ := url.Parse("http://test.com/index.html")
+
+	result := NewJSSandbox().Run(scripts, pageUrl)
+	if len(result.Errors) != 1 {
+		t.Error("incorrect errors: ", result.Errors)
+	}
+	if !ContainsString(result.Navigations, "http://test.com/later") {
+		t.Error("navigation missing: ", result.Navigations)
+	}
+	if len(result.Requests) != 2 {
+		t.Error("incorrect requests: ", result.Requests)
+	}
+
+	page := &Page{URL: pageUrl.String()}
+	MergeJSResult(page, result, pageUrl)
+	if !ContainsString(page.RespInfo.Hrefs, "http://test.com/written") {
+		t.Error("written link missing: ", page.RespInfo.Hrefs)
+	}
+}
+
+func TestJSSandboxTimeout(t *testing.T) {
+	pageUrl, _ := url.Parse("http://test.com/")
+	sb := NewJSSandbox()
+	sb.Timeout = 100 * time.Millisecond
+
+	start := time.Now()
+	result := sb.Run([]string{`while(true) {}`, `location.href = "/never"`}, pageUrl)
+	if time.Since(start) > 2*time.Second {
+		t.Error("script not interrupted")
+	}
+	if len(result.Navigations) != 0 || len(result.Errors) != 1 {
+		t.Error("scripts executed after timeout: ", result)
+	}
+}
+
+func TestExecutePageScripts(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/app/lib.js":
+			w.Header().Set("Content-Type", "application/javascript")
+			w.Write([]byte(`order += "b";`))
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><head><base href="/app/"></head><body>
+			<script>var order = "a";</script>
+			<script src="lib.js"></script>
+			<script>document.write('<a href="' + order + '">x</a>');</script>
+			</body></html>`))
+		}
+	}))
+	defer ts.Close()
+
+	cw := NewCrawler()
+	cw.StorageFolder = ""
+	cw.WaitBetweenRequests = 0
+	cw.ExecuteJS = true
+	page, err := cw.GetPage(ts.URL+"/index.html", "GET")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cw.ExecutePageScripts(page)
+	if !ContainsString(page.RespInfo.Hrefs, ts.URL+"/app/ab") {
+		t.Error("external script not run in order or base url ignored: ", page.RespInfo.Hrefs)
+	}
+}
@@ -0,0 +1,136 @@
+package crawlbase
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+var jsScriptTypes = []string{"", "text/javascript", "application/javascript", "module", "text/ecmascript", "application/ecmascript"}
+var jsUrlAttrs = []string{"href", "src", "action", "formaction"}
+
+var regFindJSUrl *regexp.Regexp = regexp.MustCompile(`(?:(?:https?|wss?):)?//[a-zA-Z0-9.-]+(?::[0-9]+)?(?:/[a-zA-Z0-9+&@#/%?=~_()|!:,.;-]*)?`)
+
+// quoted strings which look like a path or a file with a server side extension
+var regFindJSPath *regexp.Regexp = regexp.MustCompile(`["'\x60](/[a-zA-Z0-9_\-.~/%]*(?:\?[a-zA-Z0-9_\-.~/%=&+]*)?|[a-zA-Z0-9_\-./]+\.(?:php|aspx?|jsp|html?|json|xml|do|action|cgi)(?:\?[a-zA-Z0-9_\-.~/%=&+]*)?)["'\x60]`)
+
+// GetJSInfo collects inline scripts, event handlers, javascript: urls and
+// json data blocks
+func GetJSInfo(doc *goquery.Document) []JSInfo {
+	infos := []JSInfo{}
+
+	doc.Find("script").Each(func(i int, s *goquery.Selection) {
+		if _, hasSrc := s.Attr("src"); hasSrc {
+			return
+		}
+		scriptType, _ := s.Attr("type")
+		scriptType = strings.ToLower(strings.TrimSpace(scriptType))
+		code := strings.TrimSpace(s.Text())
+		if code == "" {
+			return
+		}
+
+		if ContainsString(jsScriptTypes, scriptType) {
+			infos = append(infos, JSInfo{Source: "script", Value: code})
+		} else if strings.Contains(scriptType, "json") {
+			infos = append(infos, JSInfo{Source: "script:" + scriptType, Value: code})
+		}
+	})
+
+	doc.Find("*").Each(func(i int, s *goquery.Selection) {
+		tag := goquery.NodeName(s)
+		for _, attr := range s.Get(0).Attr {
+			name := strings.ToLower(attr.Key)
+			value := strings.TrimSpace(attr.Val)
+			switch {
+			case strings.HasPrefix(name, "on") && value != "":
+				infos = append(infos, JSInfo{Source: "event:" + tag + "." + name, Value: value})
+			case ContainsString(jsUrlAttrs, name) && len(value) > 11 && strings.EqualFold(value[:11], "javascript:"):
+				code, err := url.PathUnescape(value[11:])
+				if err != nil {
+					code = value[11:]
+				}
+				infos = append(infos, JSInfo{Source: "url:" + tag + "." + name, Value: code})
+			case strings.HasPrefix(name, "data-") && (strings.HasPrefix(value, "{") || strings.HasPrefix(value, "[")):
+				infos = append(infos, JSInfo{Source: "data:" + tag + "." + name, Value: value})
+			}
+		}
+	})
+
+	return infos
+}
+
+// GetUrlsFromJS finds absolute urls and quoted paths in javascript code
+func GetUrlsFromJS(code string, baseUrl *url.URL) []string {
+	urls := []string{}
+	add := func(rawUrl string) {
+		absUrl := ToAbsUrl(baseUrl, rawUrl)
+		u, err := url.Parse(absUrl)
+		// drop comments like //todo matched as protocol relative urls
+		if err != nil || u.Host == "" || !strings.ContainsAny(u.Host, ".:") {
+			return
+		}
+		if !ContainsString(urls, absUrl) {
+			urls = append(urls, absUrl)
+		}
+	}
+
+	for _, u := range regFindJSUrl.FindAllString(code, -1) {
+		add(u)
+	}
+	for _, m := range regFindJSPath.FindAllStringSubmatch(code, -1) {
+		// skip regex literals and comment markers
+		if m[1] == "/" || strings.HasPrefix(m[1], "//") {
+			continue
+		}
+		add(m[1])
+	}
+	return urls
+}
+
+// GetJSInfoRequests resolves the urls found in JSInfo values
+func GetJSInfoRequests(infos []JSInfo, baseUrl *url.URL) []Ressource {
+	requests := []Ressource{}
+	seen := []string{}
+	for _, info := range infos {
+		for _, u := range GetUrlsFromJS(info.Value, baseUrl) {
+			if ContainsString(seen, u) {
+				continue
+			}
+			seen = append(seen, u)
+			requests = append(requests, Ressource{Url: u, Tag: "js", Source: info.Source})
+		}
+	}
+	return requests
+}
+
+// LinksFromPage returns the hrefs and the urls found as plain strings in
+// scripts. Api calls like fetch, xhr or websockets are kept in Requests only,
+// strings matching an api call or a destructive action are skipped.
+func LinksFromPage(page *Page) []string {
+	links := append([]string{}, page.RespInfo.Hrefs...)
+	calls := []string{}
+	for _, req := range page.RespInfo.Requests {
+		if !isScriptString(req) {
+			calls = append(calls, req.Url)
+		}
+	}
+	for _, req := range page.RespInfo.Requests {
+		if req.Url == "" || !isScriptString(req) || ContainsString(calls, req.Url) {
+			continue
+		}
+		if DestructiveFormPattern.MatchString(req.Url) || ContainsString(links, req.Url) {
+			continue
+		}
+		links = append(links, req.Url)
+	}
+	return links
+}
+
+// isScriptString reports urls found as string literals in inline or fetched
+// scripts
+func isScriptString(req Ressource) bool {
+	return req.Tag == "js" || (req.Tag == "js-endpoint" && req.Type == "string")
+}
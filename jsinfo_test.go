@@ -0,0 +1,53 @@
+package crawlbase
+
+import (
+	"bytes"
+	"net/url"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestGetJSInfo(t *testing.T) {
+	str := `<script>fetch("/api/users?id=1"); // todo
+	var cdn = "https://cdn.test.com/lib.js";</script>
+	<script type="application/json">{"next": "/page/2"}</script>
+	<button onclick="location.href='/checkout'">buy</button>
+	<a href="javascript:openWindow('help.html')">help</a>
+	<div data-config='{"endpoint": "/api/config"}'></div>`
+	doc, _ := goquery.NewDocumentFromReader(bytes.NewReader([]byte(str)))
+	testUrl, _ := url.Parse("http://test.com/shop/")
+
+	infos := GetJSInfo(doc)
+	sources := []string{}
+	for _, info := range infos {
+		sources = append(sources, info.Source)
+	}
+	expected := []string{"script", "script:application/json", "event:button.onclick", "url:a.href", "data:div.data-config"}
+	for _, source := range expected {
+		if !ContainsString(sources, source) {
+			t.Error("missing source ", source, " in ", sources)
+		}
+	}
+
+	urls := []string{}
+	for _, req := range GetJSInfoRequests(infos, testUrl) {
+		urls = append(urls, req.Url)
+	}
+	expected = []string{
+		"http://test.com/api/users?id=1",
+		"https://cdn.test.com/lib.js",
+		"http://test.com/page/2",
+		"http://test.com/checkout",
+		"http://test.com/shop/help.html",
+		"http://test.com/api/config",
+	}
+	for _, u := range expected {
+		if !ContainsString(urls, u) {
+			t.Error("missing url ", u, " in ", urls)
+		}
+	}
+	if len(urls) != len(expected) {
+		t.Error("unexpected urls: ", urls)
+	}
+}
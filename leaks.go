@@ -0,0 +1,419 @@
+package crawlbase
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+const (
+	LeakEmail        = "email"
+	LeakPhone        = "phone"
+	LeakInternalHost = "internal-host"
+	LeakPrivateIP    = "private-ip"
+	LeakIBAN         = "iban"
+	LeakCard         = "card"
+	LeakComment      = "comment"
+)
+
+// Leak is sensitive information found in a response. Personal data like
+// emails, phone numbers, ibans and cards is redacted, Hash is a hmac of the
+// full value to find it on other pages.
+type Leak struct {
+	Type     string
+	Value    string
+	Location string `json:",omitempty"` // html or js for comments
+	Hash     string `json:",omitempty"`
+}
+
+// SiteLeaks aggregates the distinct leaked values of a host by type
+type SiteLeaks struct {
+	Host   string
+	Values map[string][]string
+	Pages  map[string][]string // urls of the pages of each value, by type and value or hash
+}
+
+// leak hashes are only comparable within one process
+var leakHashKey []byte
+var leakKeyOnce sync.Once
+
+func hashLeak(value string) string {
+	leakKeyOnce.Do(func() {
+		leakHashKey = make([]byte, 32)
+		_, err := rand.Read(leakHashKey)
+		if err != nil {
+			log.Fatal(err)
+		}
+	})
+	mac := hmac.New(sha256.New, leakHashKey)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// RedactLeak masks personal data, cards, ibans and phone numbers keep
+// their last 4 digits, emails the first letter and the domain
+func RedactLeak(leakType, value string) string {
+	switch leakType {
+	case LeakCard, LeakIBAN, LeakPhone:
+		return maskAlnum(value, 4)
+	case LeakEmail:
+		i := strings.LastIndex(value, "@")
+		if i < 1 {
+			return RedactSecret(value)
+		}
+		return value[:1] + strings.Repeat("*", i-1) + value[i:]
+	}
+	return value
+}
+
+// maskAlnum replaces all letters and digits but the last keep ones,
+// separators are kept
+func maskAlnum(value string, keep int) string {
+	masked := []byte(value)
+	for i := len(masked) - 1; i >= 0; i-- {
+		ch := masked[i]
+		isAlnum := (ch >= '0' && ch <= '9') || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+		if !isAlnum {
+			continue
+		}
+		if keep > 0 {
+			keep--
+			continue
+		}
+		masked[i] = '*'
+	}
+	return string(masked)
+}
+
+func isPersonalLeak(leakType string) bool {
+	return leakType == LeakEmail || leakType == LeakPhone || leakType == LeakIBAN || leakType == LeakCard
+}
+
+// Key identifies the leaked value, by hash for redacted values
+func (l Leak) Key() string {
+	if l.Hash != "" {
+		return l.Type + " " + l.Hash
+	}
+	return l.Type + " " + l.Value
+}
+
+var regFindEmail *regexp.Regexp = regexp.MustCompile(`[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}`)
+var regFindPhone *regexp.Regexp = regexp.MustCompile(`\+[1-9][0-9 ()./-]{6,18}[0-9]|tel:[+0-9 ()./-]{6,20}[0-9]`)
+var regFindInternalHost *regexp.Regexp = regexp.MustCompile(`(?i)\b(?:[a-z0-9](?:[a-z0-9-]*[a-z0-9])?\.)+(?:local|localdomain|internal|intranet|corp|lan|home\.arpa)\b`)
+var regFindIBAN *regexp.Regexp = regexp.MustCompile(`\b[A-Z]{2}[0-9]{2}(?: ?[A-Z0-9]{4}){2,7}(?: ?[A-Z0-9]{1,3})?\b`)
+var regFindCard *regexp.Regexp = regexp.MustCompile(`\b[3-6][0-9]{3}(?:[ -]?[0-9]{2,4}){3,4}\b`)
+var regFindJSComment *regexp.Regexp = regexp.MustCompile(`(?s)/\*(.*?)\*/|(?m)(?:^|[^:"'\\/])//([^\n]*)$`)
+
+// file names like logo@2x.png look like emails
+var emailFileSuffixes = []string{".png", ".jpg", ".jpeg", ".gif", ".svg", ".webp", ".js", ".css"}
+
+func GetEmailsFromText(text []byte, max int) [][]byte {
+	emails := [][]byte{}
+	for _, m := range regFindEmail.FindAll(text, max) {
+		lower := strings.ToLower(string(m))
+		isFile := false
+		for _, suffix := range emailFileSuffixes {
+			if strings.HasSuffix(lower, suffix) {
+				isFile = true
+			}
+		}
+		if !isFile {
+			emails = append(emails, m)
+		}
+	}
+	return emails
+}
+
+// GetPhonesFromText returns numbers in international +format and tel: urls
+func GetPhonesFromText(text []byte, max int) [][]byte {
+	phones := [][]byte{}
+	for _, m := range regFindPhone.FindAll(text, max) {
+		m = bytes.TrimPrefix(m, []byte("tel:"))
+		digits := 0
+		for _, c := range m {
+			if c >= '0' && c <= '9' {
+				digits++
+			}
+		}
+		if digits >= 8 && digits <= 15 {
+			phones = append(phones, m)
+		}
+	}
+	return phones
+}
+
+func GetInternalHostsFromText(text []byte, max int) [][]byte {
+	return regFindInternalHost.FindAll(text, max)
+}
+
+// GetPrivateIPsFromText returns private, loopback and link local addresses
+func GetPrivateIPsFromText(text []byte, max int) [][]byte {
+	ips := [][]byte{}
+	for _, m := range GetIPsFromText(text, max) {
+		if IsPrivateIP(string(m)) {
+			ips = append(ips, m)
+		}
+	}
+	return ips
+}
+
+func IsPrivateIP(s string) bool {
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return false
+	}
+	return ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast()
+}
+
+// GetIBANsFromText returns IBANs with a valid checksum
+func GetIBANsFromText(text []byte, max int) [][]byte {
+	ibans := [][]byte{}
+	for _, m := range regFindIBAN.FindAll(text, max) {
+		if ValidIBAN(string(m)) {
+			ibans = append(ibans, m)
+		}
+	}
+	return ibans
+}
+
+// ValidIBAN checks the length and the mod 97 checksum
+func ValidIBAN(iban string) bool {
+	iban = strings.ToUpper(strings.Replace(iban, " ", "", -1))
+	if len(iban) < 15 || len(iban) > 34 {
+		return false
+	}
+	rearranged := iban[4:] + iban[:4]
+	digits := ""
+	for _, c := range rearranged {
+		switch {
+		case c >= '0' && c <= '9':
+			digits += string(c)
+		case c >= 'A' && c <= 'Z':
+			digits += big.NewInt(int64(c - 'A' + 10)).String()
+		default:
+			return false
+		}
+	}
+	n, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return false
+	}
+	return new(big.Int).Mod(n, big.NewInt(97)).Int64() == 1
+}
+
+// GetCardNumbersFromText returns card like numbers passing the luhn check
+func GetCardNumbersFromText(text []byte, max int) [][]byte {
+	cards := [][]byte{}
+	for _, m := range regFindCard.FindAll(text, max) {
+		if ValidLuhn(string(m)) {
+			cards = append(cards, m)
+		}
+	}
+	return cards
+}
+
+// ValidLuhn checks numbers with 13 to 19 digits, spaces and dashes are
+// ignored
+func ValidLuhn(number string) bool {
+	digits := []int{}
+	for _, c := range number {
+		switch {
+		case c >= '0' && c <= '9':
+			digits = append(digits, int(c-'0'))
+		case c == ' ' || c == '-':
+		default:
+			return false
+		}
+	}
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+	sum := 0
+	for i := range digits {
+		d := digits[len(digits)-1-i]
+		if i%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+// GetHtmlComments returns the non empty comments of a document
+func GetHtmlComments(doc *goquery.Document) []string {
+	comments := []string{}
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.CommentNode {
+			if c := strings.TrimSpace(n.Data); c != "" {
+				comments = append(comments, c)
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	for _, n := range doc.Nodes {
+		walk(n)
+	}
+	return comments
+}
+
+// GetJSComments returns the block and line comments of a script
+func GetJSComments(code string) []string {
+	comments := []string{}
+	for _, m := range regFindJSComment.FindAllStringSubmatch(code, -1) {
+		if c := strings.TrimSpace(m[1] + m[2]); c != "" {
+			comments = append(comments, c)
+		}
+	}
+	return comments
+}
+
+// FindLeaks extracts emails, phone numbers, internal hosts, private ips,
+// IBANs, card numbers and comments of a text response
+func FindLeaks(page *Page) []Leak {
+	leaks := []Leak{}
+	seen := map[string]bool{}
+	add := func(leakType, value, location string) {
+		key := leakType + " " + location + " " + value
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		leak := Leak{Type: leakType, Value: value, Location: location}
+		if isPersonalLeak(leakType) {
+			leak.Value = RedactLeak(leakType, value)
+			leak.Hash = hashLeak(value)
+		}
+		leaks = append(leaks, leak)
+	}
+
+	mime := ""
+	if page.Response != nil {
+		mime = page.Response.ContentMIME
+	}
+	if mime != "" && !isTextMime(mime) {
+		return leaks
+	}
+
+	text := page.ResponseBody
+	finders := []struct {
+		Type string
+		Find func([]byte, int) [][]byte
+	}{
+		{LeakEmail, GetEmailsFromText},
+		{LeakPhone, GetPhonesFromText},
+		{LeakInternalHost, GetInternalHostsFromText},
+		{LeakPrivateIP, GetPrivateIPsFromText},
+		{LeakIBAN, GetIBANsFromText},
+		{LeakCard, GetCardNumbersFromText},
+	}
+	for _, f := range finders {
+		for _, m := range f.Find(text, -1) {
+			add(f.Type, string(m), "")
+		}
+	}
+
+	if mime == "" || mime == "text/html" {
+		doc, err := goquery.NewDocumentFromReader(bytes.NewReader(text))
+		if err == nil {
+			for _, c := range GetHtmlComments(doc) {
+				add(LeakComment, c, "html")
+			}
+		}
+		for _, info := range page.RespInfo.JSInfo {
+			if info.Source == "script" {
+				for _, c := range GetJSComments(info.Value) {
+					add(LeakComment, c, "js")
+				}
+			}
+		}
+	} else if strings.Contains(mime, "javascript") {
+		for _, c := range GetJSComments(string(text)) {
+			add(LeakComment, c, "js")
+		}
+	}
+	return leaks
+}
+
+// AddLeaks adds the leaks of a page to the report of its host
+func AddLeaks(sites map[string]*SiteLeaks, page *Page) {
+	if len(page.Leaks) == 0 {
+		return
+	}
+	host := page.URL
+	if u, err := url.Parse(page.URL); err == nil {
+		host = u.Host
+	}
+	site, ok := sites[host]
+	if !ok {
+		site = &SiteLeaks{Host: host, Values: map[string][]string{}, Pages: map[string][]string{}}
+		sites[host] = site
+	}
+	for _, leak := range page.Leaks {
+		key := leak.Key()
+		if _, ok := site.Pages[key]; !ok {
+			site.Values[leak.Type] = append(site.Values[leak.Type], leak.Value)
+		}
+		if !ContainsString(site.Pages[key], page.URL) {
+			site.Pages[key] = append(site.Pages[key], page.URL)
+		}
+	}
+}
+
+// LeakReport aggregates the leaks of stored pages per host
+func LeakReport(pages []*Page) []SiteLeaks {
+	sites := map[string]*SiteLeaks{}
+	for _, page := range pages {
+		AddLeaks(sites, page)
+	}
+	return sortedSiteLeaks(sites)
+}
+
+func sortedSiteLeaks(sites map[string]*SiteLeaks) []SiteLeaks {
+	report := []SiteLeaks{}
+	for _, site := range sites {
+		report = append(report, *site)
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].Host < report[j].Host })
+	return report
+}
+
+// ScanPageLeaks stores the leaks on the page and in the site report of
+// the crawler
+func (c *Crawler) ScanPageLeaks(page *Page) {
+	page.Leaks = FindLeaks(page)
+	if c.leaks == nil {
+		c.leaks = map[string]*SiteLeaks{}
+	}
+	AddLeaks(c.leaks, page)
+}
+
+func (c *Crawler) LeakReport() []SiteLeaks {
+	return sortedSiteLeaks(c.leaks)
+}
+
+func (c *Crawler) WriteLeakReport(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(c.LeakReport())
+}
@@ -0,0 +1,84 @@
+package crawlbase
+
+import (
+	"testing"
+)
+
+func TestValidIBANAndLuhn(t *testing.T) {
+	if !ValidIBAN("DE89 3704 0044 0532 0130 00") || ValidIBAN("DE89 3704 0044 0532 0130 01") {
+		t.Error("wrong iban check")
+	}
+	if !ValidLuhn("4111 1111 1111 1111") || ValidLuhn("4111 1111 1111 1112") || ValidLuhn("4111") {
+		t.Error("wrong luhn check")
+	}
+}
+
+func TestFindLeaks(t *testing.T) {
+	str := `<html><!-- TODO: remove admin login at db01.corp.internal -->
+	<p>Contact jane.doe@test.com or +49 30 1234567, <a href="tel:+1-555-123-4567">call</a></p>
+	<img src="logo@2x.png"><p>Server 10.0.0.12, public 8.8.8.8</p>
+	<p>IBAN DE89 3704 0044 0532 0130 00, card 4111-1111-1111-1111, order 4111-1111-1111-1112</p>
+	<script>var api = "http://api.test.com"; // debug endpoint
+	/* build 1.2 */</script></html>`
+	page := &Page{URL: "http://test.com/contact", Response: &PageResponse{ContentMIME: "text/html"}}
+	page.ResponseBody = []byte(str)
+	page.RespInfo.JSInfo = []JSInfo{{Source: "script", Value: `var api = "http://api.test.com"; // debug endpoint
+	/* build 1.2 */`}}
+
+	found := map[string][]string{}
+	for _, leak := range FindLeaks(page) {
+		found[leak.Type] = append(found[leak.Type], leak.Value)
+	}
+	expected := map[string][]string{
+		LeakEmail:        {"j*******@test.com"},
+		LeakPhone:        {"+** ** ***4567", "+*-***-***-4567"},
+		LeakInternalHost: {"db01.corp.internal"},
+		LeakPrivateIP:    {"10.0.0.12"},
+		LeakIBAN:         {"**** **** **** **** **30 00"},
+		LeakCard:         {"****-****-****-1111"},
+		LeakComment:      {"TODO: remove admin login at db01.corp.internal", "build 1.2", "debug endpoint"},
+	}
+	for leakType, values := range expected {
+		if len(found[leakType]) != len(values) {
+			t.Error("wrong ", leakType, " leaks ", found[leakType])
+			continue
+		}
+		for _, v := range values {
+			if !ContainsString(found[leakType], v) {
+				t.Error("missing ", leakType, " ", v, " in ", found[leakType])
+			}
+		}
+	}
+
+	c := NewCrawler()
+	c.ScanPageLeaks(page)
+	other := &Page{URL: "http://test.com/about", Response: &PageResponse{ContentMIME: "text/plain"}, ResponseBody: []byte("mail jane.doe@test.com")}
+	c.ScanPageLeaks(other)
+	report := c.LeakReport()
+	if len(report) != 1 || report[0].Host != "test.com" {
+		t.Fatal("wrong report ", report)
+	}
+	if len(report[0].Values[LeakEmail]) != 1 || len(report[0].Pages[LeakEmail+" "+hashLeak("jane.doe@test.com")]) != 2 {
+		t.Error("emails not aggregated ", report[0])
+	}
+	if len(report[0].Values[LeakPhone]) != 2 {
+		t.Error("phones with the same last digits merged ", report[0].Values[LeakPhone])
+	}
+}
+
+func TestRedactLeak(t *testing.T) {
+	tests := []struct {
+		Type, Value, Expected string
+	}{
+		{LeakCard, "4111 1111 1111 1111", "**** **** **** 1111"},
+		{LeakIBAN, "DE89370400440532013000", "******************3000"},
+		{LeakEmail, "a@test.com", "a@test.com"},
+		{LeakEmail, "admin@test.com", "a****@test.com"},
+		{LeakInternalHost, "db01.corp.internal", "db01.corp.internal"},
+	}
+	for _, test := range tests {
+		if redacted := RedactLeak(test.Type, test.Value); redacted != test.Expected {
+			t.Error("expected ", test.Expected, " got ", redacted)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package crawlbase
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Link is an anchor of a page with its attributes
+type Link struct {
+	Url          string
+	Text         string `json:",omitempty"`
+	Rel          string `json:",omitempty"`
+	Target       string `json:",omitempty"`
+	Download     string `json:",omitempty"`
+	Title        string `json:",omitempty"`
+	Hidden       bool   `json:",omitempty"`
+	HiddenReason string `json:",omitempty"`
+}
+
+// HasRel reports whether the link has one of the rel values, eg nofollow
+func (l Link) HasRel(rels ...string) bool {
+	for _, r := range strings.Fields(strings.ToLower(l.Rel)) {
+		if ContainsString(rels, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetLinks returns the anchors and area links of a page in document order,
+// links hidden by the page are marked
+func GetLinks(doc *goquery.Document, baseUrl *url.URL) []Link {
+	links := []Link{}
+	rules := GetDocumentStyleRules(doc)
+
+	doc.Find("a[href], area[href]").Each(func(i int, s *goquery.Selection) {
+		href, _ := s.Attr("href")
+		link := Link{Url: ToAbsUrl(baseUrl, href)}
+		link.Text = strings.Join(strings.Fields(s.Text()), " ")
+		if link.Text == "" {
+			// image links are labelled by the alt text
+			link.Text, _ = s.Find("img[alt]").First().Attr("alt")
+		}
+		if link.Text == "" {
+			link.Text, _ = s.Attr("alt")
+		}
+		link.Rel, _ = s.Attr("rel")
+		link.Target, _ = s.Attr("target")
+		link.Title, _ = s.Attr("title")
+		if download, exists := s.Attr("download"); exists {
+			// an empty download attribute keeps the server file name
+			link.Download = download
+			if download == "" {
+				link.Download = "true"
+			}
+		}
+		link.Hidden, link.HiddenReason = IsHiddenElement(s, rules)
+		links = append(links, link)
+	})
+
+	return links
+}
+
+// FilterLinksByRel removes urls which are only linked with one of the
+// skipped rel values, urls linked elsewhere without them are kept
+func FilterLinksByRel(page *Page, links []string, skipRels []string) []string {
+	skipped := map[string]bool{}
+	for _, l := range page.RespInfo.Links {
+		if l.HasRel(skipRels...) {
+			if _, seen := skipped[l.Url]; !seen {
+				skipped[l.Url] = true
+			}
+		} else {
+			skipped[l.Url] = false
+		}
+	}
+
+	filtered := []string{}
+	for _, l := range links {
+		if !skipped[l] {
+			filtered = append(filtered, l)
+		}
+	}
+	return filtered
+}
+
+// WriteLinkGraph writes the links between pages as graphviz dot, edges are
+// labelled with the anchor text
+func WriteLinkGraph(w io.Writer, pages []*Page) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph links {")
+	for _, page := range pages {
+		edges := map[string]bool{}
+		for _, l := range page.RespInfo.Links {
+			key := l.Url + "\x00" + l.Text
+			if edges[key] {
+				continue
+			}
+			edges[key] = true
+			attrs := "label=" + strconv.Quote(l.Text)
+			if l.HasRel("nofollow", "sponsored", "ugc") {
+				attrs += ", style=dashed"
+			}
+			fmt.Fprintf(bw, "\t%s -> %s [%s];\n", strconv.Quote(page.URL), strconv.Quote(l.Url), attrs)
+		}
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+// ExportLinkGraph writes the link graph of a storage folder to dotFile
+func ExportLinkGraph(folder, dotFile string, filter func(*Page) bool) error {
+	pages, err := LoadAllPages(folder, false, filter)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(dotFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return WriteLinkGraph(f, pages)
+}
@@ -0,0 +1,57 @@
+package crawlbase
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestGetLinks(t *testing.T) {
+	str := `<a href="/about" title="About us"> About
+	us </a><a href="/ad" rel="Sponsored nofollow" target="_blank">Ad</a>
+	<a href="/file.pdf" download><img src="pdf.png" alt="Report"></a>
+	<a href="/both" rel="ugc">user</a><a href="/both">both</a>`
+	doc, _ := goquery.NewDocumentFromReader(bytes.NewReader([]byte(str)))
+	testUrl, _ := url.Parse("http://test.com/")
+
+	links := GetLinks(doc, testUrl)
+	if len(links) != 5 {
+		t.Fatal("expected 5 links, got ", links)
+	}
+	if links[0].Text != "About us" || links[0].Title != "About us" {
+		t.Error("wrong text or title ", links[0])
+	}
+	if !links[1].HasRel("nofollow") || !links[1].HasRel("sponsored") || links[1].Target != "_blank" {
+		t.Error("wrong rel or target ", links[1])
+	}
+	if links[2].Text != "Report" || links[2].Download != "true" {
+		t.Error("wrong image link ", links[2])
+	}
+
+	page := &Page{URL: "http://test.com/"}
+	page.RespInfo.Links = links
+	hrefs := []string{}
+	for _, l := range links {
+		hrefs = append(hrefs, l.Url)
+	}
+	filtered := FilterLinksByRel(page, hrefs, []string{"nofollow", "ugc"})
+	if ContainsString(filtered, "http://test.com/ad") || !ContainsString(filtered, "http://test.com/both") {
+		t.Error("wrong filtered links ", filtered)
+	}
+
+	buf := &bytes.Buffer{}
+	err := WriteLinkGraph(buf, []*Page{page})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dot := buf.String()
+	if !strings.Contains(dot, `"http://test.com/" -> "http://test.com/about" [label="About us"];`) {
+		t.Error("edge missing in ", dot)
+	}
+	if !strings.Contains(dot, `"http://test.com/ad" [label="Ad", style=dashed]`) {
+		t.Error("nofollow edge not dashed in ", dot)
+	}
+}
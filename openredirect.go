@@ -0,0 +1,255 @@
+package crawlbase
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// host the redirect payloads point to
+const DefaultRedirectAttacker = DefaultCORSAttacker
+
+// parameter names which usually hold a redirect target
+var RedirectParamNames = []string{
+	"next", "url", "uri", "redirect", "redirect_uri", "redirect_url", "redirecturl", "redir",
+	"return", "return_to", "returnto", "returnurl", "return_url", "goto", "to", "dest",
+	"destination", "continue", "target", "forward", "out", "callback", "location", "r", "u",
+}
+
+var redirectParamParts = []string{"redirect", "return", "url", "next", "goto", "dest"}
+
+// RedirectCandidate is a parameter of a link or form which may control a
+// redirect
+type RedirectCandidate struct {
+	Url    string // link url or form action
+	Method string
+	Param  string
+	Form   *Form `json:",omitempty"`
+}
+
+type RedirectPayload struct {
+	Name  string
+	Value string
+}
+
+type RedirectFinding struct {
+	Url        string
+	Method     string
+	Param      string
+	Payload    string
+	Value      string
+	Via        string // location or meta-refresh
+	Target     string
+	StatusCode int
+}
+
+// IsRedirectParam reports whether a parameter looks like a redirect target
+// by its name or its value
+func IsRedirectParam(name, value string) bool {
+	name = strings.ToLower(name)
+	if ContainsString(RedirectParamNames, name) {
+		return true
+	}
+	for _, part := range redirectParamParts {
+		if strings.Contains(name, part) {
+			return true
+		}
+	}
+	value = strings.ToLower(value)
+	return strings.HasPrefix(value, "http://") || strings.HasPrefix(value, "https://") ||
+		strings.HasPrefix(value, "//") || strings.HasPrefix(value, "%2f") ||
+		(strings.HasPrefix(value, "/") && len(value) > 1)
+}
+
+// FindRedirectCandidates collects redirect like parameters of the hrefs
+// and forms of pages, each parameter of an url or form is returned once.
+// Links and forms to other domains than their page are skipped.
+func FindRedirectCandidates(pages []*Page) []RedirectCandidate {
+	candidates := []RedirectCandidate{}
+	seen := map[string]bool{}
+	add := func(c RedirectCandidate) {
+		key := c.Method + " " + strings.SplitN(c.Url, "?", 2)[0] + " " + c.Param
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		candidates = append(candidates, c)
+	}
+
+	for _, page := range pages {
+		pageUrl, err := url.Parse(page.URL)
+		if err != nil {
+			continue
+		}
+		for _, href := range page.RespInfo.Hrefs {
+			hrefUrl, err := url.Parse(href)
+			if err != nil || !IsSameDomain(pageUrl, hrefUrl) {
+				continue
+			}
+			for name, values := range hrefUrl.Query() {
+				if IsRedirectParam(name, strings.Join(values, "")) {
+					add(RedirectCandidate{Url: href, Method: "GET", Param: name})
+				}
+			}
+		}
+		for i := range page.RespInfo.Forms {
+			form := page.RespInfo.Forms[i]
+			if IsDestructiveForm(form) {
+				continue
+			}
+			if form.Url == "" {
+				form.Url = page.URL
+			}
+			if formUrl, err := url.Parse(form.Url); err != nil || !IsSameDomain(pageUrl, formUrl) {
+				continue
+			}
+			method := strings.ToUpper(form.Method)
+			if method != "POST" {
+				method = "GET"
+			}
+			for _, input := range form.Inputs {
+				if input.Name != "" && IsRedirectParam(input.Name, input.Value) {
+					add(RedirectCandidate{Url: form.Url, Method: method, Param: input.Name, Form: &form})
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].Url < candidates[j].Url })
+	return candidates
+}
+
+// RedirectPayloads returns absolute, protocol relative, backslash, userinfo,
+// prefix and encoded variants pointing to attacker
+func RedirectPayloads(target *url.URL, attacker string) []RedirectPayload {
+	return []RedirectPayload{
+		{"absolute", "https://" + attacker + "/"},
+		{"protocol-relative", "//" + attacker + "/"},
+		{"backslash", "/\\" + attacker + "/"},
+		{"triple-slash", "///" + attacker + "/"},
+		{"userinfo", "https://" + target.Hostname() + "@" + attacker + "/"},
+		{"prefix", "https://" + target.Hostname() + "." + attacker + "/"},
+		{"encoded", "%2F%2F" + attacker + "%2F"},
+		{"tab", "/\t/" + attacker + "/"},
+	}
+}
+
+// redirectTarget resolves a redirect the way browsers do, backslashes are
+// slashes and tabs and newlines are removed
+func redirectTarget(raw string, base *url.URL) *url.URL {
+	raw = strings.TrimSpace(raw)
+	raw = strings.NewReplacer("\\", "/", "\t", "", "\n", "", "\r", "").Replace(raw)
+	if strings.HasPrefix(raw, "///") {
+		// any number of slashes starts the host
+		raw = "//" + strings.TrimLeft(raw, "/")
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	return u
+}
+
+// IsOffsiteRedirect reports whether a redirect reaches attacker
+func IsOffsiteRedirect(raw string, base *url.URL, attacker string) bool {
+	u := redirectTarget(raw, base)
+	if u == nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	return host == attacker || strings.HasSuffix(host, "."+attacker)
+}
+
+func (c *Crawler) redirectRequest(candidate RedirectCandidate, value string) (*http.Request, error) {
+	if candidate.Form != nil {
+		ff := c.FormFiller
+		if ff == nil {
+			ff = NewFormFiller()
+		}
+		values := ff.Fill(*candidate.Form, nil)
+		values.Set(candidate.Param, value)
+		req, _, err := NewFormRequest(candidate.Url, *candidate.Form, values, nil)
+		return req, err
+	}
+
+	u, err := url.Parse(candidate.Url)
+	if err != nil {
+		return nil, err
+	}
+	query := u.Query()
+	query.Set(candidate.Param, value)
+	u.RawQuery = query.Encode()
+	return http.NewRequest("GET", u.String(), nil)
+}
+
+// CheckOpenRedirect sends each payload in the parameter of candidate and
+// reports the responses redirecting to the attacker host. Redirects are not
+// followed, the crawler client stops at the first response.
+func (c *Crawler) CheckOpenRedirect(candidate RedirectCandidate) []RedirectFinding {
+	findings := []RedirectFinding{}
+	target, err := url.Parse(candidate.Url)
+	if err != nil {
+		return findings
+	}
+
+	for _, payload := range RedirectPayloads(target, DefaultRedirectAttacker) {
+		req, err := c.redirectRequest(candidate, payload.Value)
+		if err != nil {
+			log.Println("CheckOpenRedirect ", err)
+			continue
+		}
+		page, err := c.DoRequest(req)
+		if err != nil || page.Response == nil {
+			log.Println("CheckOpenRedirect ", err)
+			continue
+		}
+
+		finding := RedirectFinding{
+			Url:        req.URL.String(),
+			Method:     req.Method,
+			Param:      candidate.Param,
+			Payload:    payload.Name,
+			Value:      payload.Value,
+			StatusCode: page.Response.StatusCode,
+		}
+		location := page.Response.Header.Get("Location")
+		if page.Response.StatusCode >= 300 && page.Response.StatusCode < 400 && IsOffsiteRedirect(location, req.URL, DefaultRedirectAttacker) {
+			finding.Via = "location"
+			finding.Target = location
+		} else if page.RespInfo.MetaRefresh != "" && IsOffsiteRedirect(page.RespInfo.MetaRefresh, req.URL, DefaultRedirectAttacker) {
+			finding.Via = "meta-refresh"
+			finding.Target = page.RespInfo.MetaRefresh
+		} else {
+			continue
+		}
+		findings = append(findings, finding)
+	}
+	return findings
+}
+
+// CheckOpenRedirects tests the redirect candidates of pages which pass
+// CheckScope for startUrl
+func (c *Crawler) CheckOpenRedirects(pages []*Page, startUrl *url.URL) []RedirectFinding {
+	findings := []RedirectFinding{}
+	for _, candidate := range FindRedirectCandidates(pages) {
+		if _, err := c.CheckScope(candidate.Url, startUrl); err != nil {
+			log.Println("CheckOpenRedirects skipping ", candidate.Url, err)
+			continue
+		}
+		findings = append(findings, c.CheckOpenRedirect(candidate)...)
+	}
+	return findings
+}
+
+func WriteRedirectReport(w io.Writer, findings []RedirectFinding) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(findings)
+}
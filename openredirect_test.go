@@ -0,0 +1,103 @@
+package crawlbase
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestFindRedirectCandidates(t *testing.T) {
+	page := &Page{URL: "http://test.com/"}
+	page.RespInfo.Hrefs = []string{
+		"http://test.com/login?next=/account&lang=en",
+		"http://test.com/login?next=/orders",
+		"http://test.com/search?q=shoes",
+		"http://test.com/view?file=/docs/a.pdf",
+		"https://facebook.com/sharer?u=http://test.com/",
+	}
+	page.RespInfo.Forms = []Form{
+		{Url: "http://test.com/session", Method: "post", Inputs: []FormInput{{Name: "return_to", Type: "hidden"}, {Name: "user"}}},
+		{Url: "http://other.test/subscribe", Method: "post", Inputs: []FormInput{{Name: "redirect", Type: "hidden"}}},
+	}
+
+	params := []string{}
+	for _, c := range FindRedirectCandidates([]*Page{page}) {
+		params = append(params, c.Method+" "+c.Param)
+	}
+	expected := []string{"GET next", "GET file", "POST return_to"}
+	if len(params) != len(expected) {
+		t.Error("wrong candidates ", params)
+	}
+	for _, p := range expected {
+		if !ContainsString(params, p) {
+			t.Error("missing candidate ", p, " in ", params)
+		}
+	}
+}
+
+func TestIsOffsiteRedirect(t *testing.T) {
+	base, _ := url.Parse("https://test.com/login")
+	offsite := []string{"https://" + DefaultRedirectAttacker, "//" + DefaultRedirectAttacker, "/\\" + DefaultRedirectAttacker, "///" + DefaultRedirectAttacker, "https://test.com@" + DefaultRedirectAttacker}
+	for _, raw := range offsite {
+		if !IsOffsiteRedirect(raw, base, DefaultRedirectAttacker) {
+			t.Error("not offsite ", raw)
+		}
+	}
+	for _, raw := range []string{"/account", "https://test.com/", "%2F%2F" + DefaultRedirectAttacker, "https://" + DefaultRedirectAttacker + ".test.com/"} {
+		if IsOffsiteRedirect(raw, base, DefaultRedirectAttacker) {
+			t.Error("offsite ", raw)
+		}
+	}
+}
+
+func TestCheckOpenRedirects(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			// blocks absolute urls only
+			next := r.URL.Query().Get("next")
+			if strings.HasPrefix(next, "http") {
+				next = "/home"
+			}
+			w.Header().Set("Location", next)
+			w.WriteHeader(302)
+		case "/out":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprintf(w, `<meta http-equiv="refresh" content="0; url=%s">`, r.FormValue("url"))
+		}
+	}))
+	defer ts.Close()
+
+	page := &Page{URL: ts.URL}
+	page.RespInfo.Hrefs = []string{ts.URL + "/login?next=/account"}
+	page.RespInfo.Forms = []Form{{Url: ts.URL + "/out", Method: "POST", Inputs: []FormInput{{Name: "url", Type: "hidden", Value: "/"}}}}
+
+	c := NewCrawler()
+	c.StorageFolder = ""
+	startUrl, _ := url.Parse(ts.URL)
+	c.ScopeToDomain = true
+	findings := c.CheckOpenRedirects([]*Page{page}, startUrl)
+
+	found := map[string]bool{}
+	for _, f := range findings {
+		found[f.Via+" "+f.Param+" "+f.Payload] = true
+	}
+	expected := []string{
+		"location next protocol-relative",
+		"location next backslash",
+		"location next triple-slash",
+		"meta-refresh url absolute",
+		"meta-refresh url userinfo",
+	}
+	for _, e := range expected {
+		if !found[e] {
+			t.Error("missing finding ", e)
+		}
+	}
+	if found["location next absolute"] || found["location next encoded"] {
+		t.Error("blocked payload reported ", findings)
+	}
+}
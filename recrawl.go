@@ -0,0 +1,259 @@
+package crawlbase
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// headers which change on every response and are ignored when diffing
+var VolatileHeaders = []string{"Date", "Age", "Expires", "X-Request-Id"}
+
+type PageChange struct {
+	URL            string
+	Unchanged      bool `json:",omitempty"` // answered with 304
+	OldStatus      int
+	NewStatus      int
+	HeadersAdded   []string `json:",omitempty"`
+	HeadersRemoved []string `json:",omitempty"`
+	HeadersChanged []string `json:",omitempty"`
+	LinksAdded     []string `json:",omitempty"`
+	LinksRemoved   []string `json:",omitempty"`
+	FormsAdded     []string `json:",omitempty"`
+	FormsRemoved   []string `json:",omitempty"`
+	OldBodyHash    string
+	NewBodyHash    string
+}
+
+type ChangeReport struct {
+	Changed   []PageChange
+	Unchanged []string
+	Added     []string // urls not part of the previous crawl
+	Removed   []string // urls of the previous crawl which were not crawled again
+}
+
+func (pc *PageChange) HasChanges() bool {
+	return pc.OldStatus != pc.NewStatus ||
+		pc.OldBodyHash != pc.NewBodyHash ||
+		len(pc.HeadersAdded) > 0 || len(pc.HeadersRemoved) > 0 || len(pc.HeadersChanged) > 0 ||
+		len(pc.LinksAdded) > 0 || len(pc.LinksRemoved) > 0 ||
+		len(pc.FormsAdded) > 0 || len(pc.FormsRemoved) > 0
+}
+
+func DiffPages(oldPage, newPage *Page) PageChange {
+	pc := PageChange{URL: newPage.URL}
+	pc.Unchanged = newPage.Unchanged
+	pc.OldBodyHash = oldPage.BodyHash
+	pc.NewBodyHash = newPage.BodyHash
+
+	oldHeader := http.Header{}
+	newHeader := http.Header{}
+	if oldPage.Response != nil {
+		pc.OldStatus = oldPage.Response.StatusCode
+		oldHeader = oldPage.Response.Header
+	}
+	if newPage.Response != nil {
+		pc.NewStatus = newPage.Response.StatusCode
+		newHeader = newPage.Response.Header
+	}
+	if newPage.Unchanged {
+		// a 304 carries only a subset of the headers
+		pc.NewStatus = pc.OldStatus
+	} else {
+		pc.HeadersAdded, pc.HeadersRemoved, pc.HeadersChanged = DiffHeaders(oldHeader, newHeader)
+	}
+
+	pc.LinksAdded, pc.LinksRemoved = DiffStrings(oldPage.RespInfo.Hrefs, newPage.RespInfo.Hrefs)
+	pc.FormsAdded, pc.FormsRemoved = DiffStrings(formKeys(oldPage.RespInfo.Forms), formKeys(newPage.RespInfo.Forms))
+	return pc
+}
+
+func DiffHeaders(oldHeader, newHeader http.Header) (added, removed, changed []string) {
+	for k, v := range newHeader {
+		if ContainsString(VolatileHeaders, k) {
+			continue
+		}
+		oldValue, hasOld := oldHeader[k]
+		if !hasOld {
+			added = append(added, k)
+		} else if strings.Join(oldValue, "\n") != strings.Join(v, "\n") {
+			changed = append(changed, k)
+		}
+	}
+	for k := range oldHeader {
+		if ContainsString(VolatileHeaders, k) {
+			continue
+		}
+		if _, hasNew := newHeader[k]; !hasNew {
+			removed = append(removed, k)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	sort.Strings(changed)
+	return added, removed, changed
+}
+
+// DiffStrings returns the values only in b and the values only in a
+func DiffStrings(a, b []string) (added, removed []string) {
+	for _, x := range b {
+		if !ContainsString(a, x) && !ContainsString(added, x) {
+			added = append(added, x)
+		}
+	}
+	for _, x := range a {
+		if !ContainsString(b, x) && !ContainsString(removed, x) {
+			removed = append(removed, x)
+		}
+	}
+	return added, removed
+}
+
+// FormKey identifies a form by method, action and input names
+func FormKey(form Form) string {
+	names := []string{}
+	for _, input := range form.Inputs {
+		names = append(names, input.Name)
+	}
+	sort.Strings(names)
+	return strings.ToUpper(form.Method) + " " + form.Url + " (" + strings.Join(names, ",") + ")"
+}
+
+func formKeys(forms []Form) []string {
+	keys := []string{}
+	for _, form := range forms {
+		keys = append(keys, FormKey(form))
+	}
+	return keys
+}
+
+// ConditionalRequest builds a GET request validating the previous page
+// with its ETag and Last-Modified headers
+func ConditionalRequest(crawlUrl string, prev *Page) (*http.Request, error) {
+	req, err := http.NewRequest("GET", crawlUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	if prev.Response == nil {
+		return req, nil
+	}
+	if etag := prev.Response.Header.Get("ETag"); etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastModified := prev.Response.Header.Get("Last-Modified"); lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+	return req, nil
+}
+
+// LoadPreviousCrawl loads the pages of an earlier crawl, FetchSites then
+// revalidates them with conditional requests and records the changes
+func (cw *Crawler) LoadPreviousCrawl(folder string) (int, error) {
+	pages, err := LoadAllPages(folder, false, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	if cw.Previous == nil {
+		cw.Previous = map[string]*Page{}
+	}
+	cw.previousFolder = folder
+	for _, page := range pages {
+		prev, hasPrev := cw.Previous[page.URL]
+		if hasPrev && prev.CrawlTime > page.CrawlTime {
+			continue
+		}
+		cw.Previous[page.URL] = page
+		cw.AddAllLinks([]string{page.URL})
+	}
+	return len(pages), nil
+}
+
+func (cw *Crawler) fetchPage(crawlUrl string) (*Page, error) {
+	prev, hasPrev := cw.Previous[crawlUrl]
+	if !hasPrev {
+		if cw.Previous != nil {
+			cw.changes.Added = append(cw.changes.Added, crawlUrl)
+		}
+		return cw.GetPage(crawlUrl, "GET")
+	}
+
+	req, err := ConditionalRequest(crawlUrl, prev)
+	if err != nil {
+		log.Println("fetchPage ", err)
+		return nil, err
+	}
+	page, err := cw.DoRequest(req)
+
+	if page.Response != nil && page.Response.StatusCode == http.StatusNotModified {
+		page.Unchanged = true
+		page.Revisit = true
+		page.RevisitOf = prev.CrawlTime
+		page.BodyHash = prev.BodyHash
+		page.RespInfo = prev.RespInfo
+		page.ResponseBody = cw.previousBody(prev)
+		if prev.Response != nil {
+			page.Response.Header = MergeNotModifiedHeader(prev.Response.Header, page.Response.Header)
+			page.Response.ContentMIME = prev.Response.ContentMIME
+			page.Response.ContentLength = int64(len(page.ResponseBody))
+		}
+	}
+
+	pc := DiffPages(prev, page)
+	if pc.HasChanges() {
+		cw.changes.Changed = append(cw.changes.Changed, pc)
+	} else {
+		cw.changes.Unchanged = append(cw.changes.Unchanged, crawlUrl)
+	}
+	return page, err
+}
+
+// MergeNotModifiedHeader updates the headers of the cached response with
+// the headers of a 304, which only carries a subset of them
+func MergeNotModifiedHeader(cached, notModified http.Header) http.Header {
+	header := http.Header{}
+	for k, v := range cached {
+		header[k] = v
+	}
+	for k, v := range notModified {
+		header[k] = v
+	}
+	return header
+}
+
+// previousBody returns the body of a page of the previous crawl, loading it
+// from the storage folder if it was loaded without content
+func (cw *Crawler) previousBody(prev *Page) []byte {
+	if prev.ResponseBody != nil || cw.previousFolder == "" {
+		return prev.ResponseBody
+	}
+	filePath := path.Join(cw.previousFolder, strconv.Itoa(prev.CrawlTime)+".httpi")
+	body, err := loadPageBody(filePath, prev)
+	if err != nil {
+		log.Println("previousBody ", err)
+	}
+	return body
+}
+
+func (cw *Crawler) ChangeReport() ChangeReport {
+	report := cw.changes
+	report.Removed = []string{}
+	for u := range cw.Previous {
+		if !cw.IsCrawled(u) {
+			report.Removed = append(report.Removed, u)
+		}
+	}
+	sort.Strings(report.Removed)
+	return report
+}
+
+func (cw *Crawler) WriteChangeReport(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(cw.ChangeReport())
+}
@@ -0,0 +1,123 @@
+package crawlbase
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strconv"
+	"testing"
+)
+
+func TestDiffPages(t *testing.T) {
+	oldPage := &Page{URL: "http://test.com", BodyHash: "1", Response: &PageResponse{StatusCode: 200, Header: http.Header{}}}
+	oldPage.Response.Header.Set("Server", "a")
+	oldPage.Response.Header.Set("Date", "yesterday")
+	oldPage.RespInfo.Hrefs = []string{"http://test.com/1"}
+
+	newPage := &Page{URL: "http://test.com", BodyHash: "2", Response: &PageResponse{StatusCode: 200, Header: http.Header{}}}
+	newPage.Response.Header.Set("Server", "b")
+	newPage.Response.Header.Set("Date", "today")
+	newPage.RespInfo.Hrefs = []string{"http://test.com/2"}
+	newPage.RespInfo.Forms = []Form{{Url: "http://test.com/login", Method: "post"}}
+
+	pc := DiffPages(oldPage, newPage)
+	if !pc.HasChanges() {
+		t.Error("changes not detected")
+	}
+	if len(pc.HeadersChanged) != 1 || pc.HeadersChanged[0] != "Server" {
+		t.Error("incorrect header changes: ", pc.HeadersChanged)
+	}
+	if len(pc.LinksAdded) != 1 || len(pc.LinksRemoved) != 1 || len(pc.FormsAdded) != 1 {
+		t.Error("incorrect link or form changes: ", pc)
+	}
+}
+
+func TestRecrawlNotModified(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("<a href='/1'></a>"))
+	}))
+	defer ts.Close()
+
+	cw := NewCrawler()
+	prev, err := cw.GetPage(ts.URL, "GET")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cw.Previous = map[string]*Page{prev.URL: prev}
+
+	page, err := cw.fetchPage(prev.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !page.Unchanged || len(page.RespInfo.Hrefs) != 1 {
+		t.Error("304 not recorded as unchanged")
+	}
+
+	report := cw.ChangeReport()
+	if len(report.Unchanged) != 1 || len(report.Changed) != 0 {
+		t.Error("incorrect change report: ", report)
+	}
+}
+
+func TestRecrawlNotModifiedKeepsBody(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<a href='/1'></a>"))
+	}))
+	defer ts.Close()
+
+	oldFolder, err := ioutil.TempDir("", "storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(oldFolder)
+	newFolder, err := ioutil.TempDir("", "storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(newFolder)
+
+	cw := NewCrawler()
+	cw.StorageFolder = oldFolder
+	prev, err := cw.GetPage(ts.URL, "GET")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cw.SavePage(prev)
+
+	cw = NewCrawler()
+	cw.StorageFolder = newFolder
+	_, err = cw.LoadPreviousCrawl(oldFolder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err := cw.fetchPage(prev.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Response.Header.Get("ETag") != `"v1"` || page.Response.ContentMIME != "text/html" {
+		t.Error("headers of the cached response not kept ", page.Response)
+	}
+	cw.SavePage(page)
+
+	loaded, err := LoadPage(path.Join(newFolder, strconv.Itoa(page.CrawlTime)+".httpi"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(loaded.ResponseBody, prev.ResponseBody) {
+		t.Error("body of revisited page lost ", string(loaded.ResponseBody))
+	}
+}
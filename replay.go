@@ -0,0 +1,194 @@
+package crawlbase
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReplayServer serves stored pages over http. Links to recorded hosts are
+// rewritten to http://replayhost/http://recordedhost/path so navigation
+// stays on the replay server.
+type ReplayServer struct {
+	pages  map[string]*Page // method + " " + canonical url
+	hosts  []string
+	hostRe *regexp.Regexp
+	misses []ReplayMiss
+	mu     sync.Mutex
+	MissFn func(*http.Request)
+}
+
+type ReplayMiss struct {
+	Method string
+	URL    string
+	Time   int
+}
+
+// headers which do not match the replayed body anymore
+var replayDropHeaders = []string{"Content-Length", "Content-Encoding", "Transfer-Encoding"}
+
+func NewReplayServer(folder string) (*ReplayServer, error) {
+	pages, err := LoadAllPages(folder, true, nil)
+	if err != nil {
+		return nil, err
+	}
+	return NewReplayServerFromPages(pages), nil
+}
+
+func NewReplayServerFromPages(pages []*Page) *ReplayServer {
+	rs := &ReplayServer{pages: map[string]*Page{}, hosts: []string{}}
+	for _, page := range pages {
+		key := replayKey(pageMethod(page), page.URL)
+		prev, hasPrev := rs.pages[key]
+		if hasPrev && prev.CrawlTime > page.CrawlTime {
+			continue
+		}
+		rs.pages[key] = page
+
+		pUrl, err := url.Parse(page.URL)
+		if err == nil && !ContainsString(rs.hosts, pUrl.Host) {
+			rs.hosts = append(rs.hosts, pUrl.Host)
+		}
+	}
+
+	quoted := []string{}
+	for _, host := range rs.hosts {
+		quoted = append(quoted, regexp.QuoteMeta(host))
+	}
+	if len(quoted) > 0 {
+		rs.hostRe = regexp.MustCompile(`(https?:)?//(` + strings.Join(quoted, "|") + `)\b`)
+	}
+	return rs
+}
+
+func pageMethod(page *Page) string {
+	if page.Request != nil && page.Request.Method != "" {
+		return page.Request.Method
+	}
+	return "GET"
+}
+
+func replayKey(method, rawUrl string) string {
+	return strings.ToUpper(method) + " " + CanonicalUrl(rawUrl)
+}
+
+func (rs *ReplayServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	page := rs.FindPage(r)
+	if page == nil {
+		rs.addMiss(r)
+		http.NotFound(w, r)
+		return
+	}
+
+	replayHost := "http://" + r.Host
+	if r.TLS != nil {
+		replayHost = "https://" + r.Host
+	}
+
+	pUrl, _ := url.Parse(page.URL)
+	header := w.Header()
+	for k, v := range page.Response.Header {
+		if ContainsString(replayDropHeaders, k) {
+			continue
+		}
+		header[k] = v
+	}
+	if loc := header.Get("Location"); loc != "" && pUrl != nil {
+		header.Set("Location", string(rs.RewriteLinks([]byte(ToAbsUrl(pUrl, loc)), pUrl.Scheme, replayHost)))
+	}
+
+	body := page.ResponseBody
+	if isTextMime(page.Response.ContentMIME) && pUrl != nil {
+		body = rs.RewriteLinks(body, pUrl.Scheme, replayHost)
+	}
+
+	status := page.Response.StatusCode
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	if r.Method != "HEAD" {
+		w.Write(body)
+	}
+}
+
+// FindPage maps a request to a stored page by url, host header and method
+func (rs *ReplayServer) FindPage(r *http.Request) *Page {
+	candidates := []string{}
+
+	// rewritten link, the recorded url is the request path
+	target := strings.TrimPrefix(r.RequestURI, "/")
+	if strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") {
+		candidates = append(candidates, target)
+	} else {
+		candidates = append(candidates, "http://"+r.Host+r.RequestURI, "https://"+r.Host+r.RequestURI)
+		// a single recorded site can be served on any host
+		if len(rs.hosts) == 1 {
+			candidates = append(candidates, "http://"+rs.hosts[0]+r.RequestURI, "https://"+rs.hosts[0]+r.RequestURI)
+		}
+	}
+
+	methods := []string{r.Method}
+	if r.Method == "HEAD" {
+		methods = append(methods, "GET")
+	}
+
+	for _, method := range methods {
+		for _, candidate := range candidates {
+			if page, ok := rs.pages[replayKey(method, candidate)]; ok && page.Response != nil {
+				return page
+			}
+		}
+	}
+	return nil
+}
+
+// RewriteLinks points absolute and protocol relative links to recorded
+// hosts to the replay host
+func (rs *ReplayServer) RewriteLinks(body []byte, pageScheme string, replayHost string) []byte {
+	if rs.hostRe == nil {
+		return body
+	}
+	return rs.hostRe.ReplaceAllFunc(body, func(m []byte) []byte {
+		link := string(m)
+		if strings.HasPrefix(link, "//") {
+			link = pageScheme + ":" + link
+		}
+		return []byte(replayHost + "/" + link)
+	})
+}
+
+func isTextMime(mime string) bool {
+	return strings.HasPrefix(mime, "text/") ||
+		strings.Contains(mime, "javascript") ||
+		strings.Contains(mime, "json") ||
+		strings.Contains(mime, "xml")
+}
+
+func (rs *ReplayServer) addMiss(r *http.Request) {
+	log.Println("replay miss: ", r.Method, r.Host, r.RequestURI)
+
+	rs.mu.Lock()
+	rs.misses = append(rs.misses, ReplayMiss{
+		Method: r.Method,
+		URL:    r.Host + r.RequestURI,
+		Time:   int(time.Now().Unix()),
+	})
+	rs.mu.Unlock()
+
+	if rs.MissFn != nil {
+		rs.MissFn(r)
+	}
+}
+
+func (rs *ReplayServer) Misses() []ReplayMiss {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	misses := make([]ReplayMiss, len(rs.misses))
+	copy(misses, rs.misses)
+	return misses
+}
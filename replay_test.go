@@ -0,0 +1,56 @@
+package crawlbase
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReplayServer(t *testing.T) {
+	p := &Page{URL: "http://test.com/index.html", Request: &PageRequest{Method: "GET"}}
+	p.Response = &PageResponse{StatusCode: 200, ContentMIME: "text/html", Header: http.Header{}}
+	p.Response.Header.Set("X-Test", "1")
+	p.ResponseBody = []byte("<a href='http://test.com/2'></a><a href='//test.com/3'></a><a href='http://other.com/'></a>")
+
+	rs := NewReplayServerFromPages([]*Page{p})
+	ts := httptest.NewServer(rs)
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "/index.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+
+	if res.StatusCode != 200 || res.Header.Get("X-Test") != "1" {
+		t.Error("recorded response not replayed")
+	}
+	if !strings.Contains(string(body), ts.URL+"/http://test.com/2") ||
+		!strings.Contains(string(body), ts.URL+"/http://test.com/3") {
+		t.Error("links not rewritten: ", string(body))
+	}
+	if !strings.Contains(string(body), "'http://other.com/'") {
+		t.Error("link to unrecorded host rewritten")
+	}
+
+	res, err = http.Get(ts.URL + "/http://test.com/index.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != 200 {
+		t.Error("rewritten url not replayed")
+	}
+
+	res, err = http.Get(ts.URL + "/missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != 404 || len(rs.Misses()) != 1 {
+		t.Error("miss not reported")
+	}
+}
@@ -0,0 +1,230 @@
+package crawlbase
+
+import (
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+var regFindCssUrl *regexp.Regexp = regexp.MustCompile(`url\(\s*(?:"([^"]*)"|'([^']*)'|([^)'"\s]*))\s*\)`)
+var regFindCssImport *regexp.Regexp = regexp.MustCompile(`@import\s+(?:"([^"]*)"|'([^']*)')`)
+
+// element and attribute holding an url
+var mediaUrlAttrs = []struct {
+	Selector string
+	Attr     string
+}{
+	{"iframe", "src"},
+	{"frame", "src"},
+	{"video", "src"},
+	{"video", "poster"},
+	{"audio", "src"},
+	{"source", "src"},
+	{"track", "src"},
+	{"embed", "src"},
+	{"object", "data"},
+	{"input[type=image]", "src"},
+	{"html", "manifest"},
+	{"image", "href"}, // svg, xlink:href is parsed as href
+	{"use", "href"},
+}
+
+// GetMediaRessources extracts frames, media, objects, srcset candidates,
+// svg references and meta refresh targets
+func GetMediaRessources(doc *goquery.Document, baseUrl *url.URL) []Ressource {
+	ressources := []Ressource{}
+
+	for _, ua := range mediaUrlAttrs {
+		doc.Find(ua.Selector).Each(func(i int, s *goquery.Selection) {
+			href, exists := s.Attr(ua.Attr)
+			if !exists || strings.TrimSpace(href) == "" {
+				return
+			}
+			res := Ressource{Tag: goquery.NodeName(s), Url: ToAbsUrl(baseUrl, strings.TrimSpace(href))}
+			res.Type, _ = s.Attr("type")
+			if ua.Attr != "src" {
+				res.Source = ua.Attr
+			}
+			ressources = append(ressources, res)
+		})
+	}
+
+	doc.Find("img[srcset], source[srcset], link[imagesrcset]").Each(func(i int, s *goquery.Selection) {
+		srcset, exists := s.Attr("srcset")
+		if !exists {
+			srcset, _ = s.Attr("imagesrcset")
+		}
+		for _, candidate := range ParseSrcset(srcset) {
+			res := Ressource{Tag: goquery.NodeName(s), Url: ToAbsUrl(baseUrl, candidate), Source: "srcset"}
+			res.Type, _ = s.Attr("type")
+			ressources = append(ressources, res)
+		}
+	})
+
+	if refresh := GetMetaRefresh(doc, baseUrl); refresh != "" {
+		ressources = append(ressources, Ressource{Tag: "meta", Type: "refresh", Url: refresh})
+	}
+
+	return ressources
+}
+
+// ParseSrcset returns the urls of a srcset attribute
+func ParseSrcset(srcset string) []string {
+	// like the html spec, an url runs until whitespace and may contain
+	// commas, its descriptors run until the next comma
+	urls := []string{}
+	isSpace := func(c byte) bool { return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' }
+	i := 0
+	for i < len(srcset) {
+		for i < len(srcset) && (isSpace(srcset[i]) || srcset[i] == ',') {
+			i++
+		}
+		start := i
+		for i < len(srcset) && !isSpace(srcset[i]) {
+			i++
+		}
+		u := srcset[start:i]
+		if u == "" {
+			break
+		}
+		if strings.HasSuffix(u, ",") {
+			u = strings.TrimRight(u, ",")
+		} else {
+			inParens := false
+			for i < len(srcset) && (srcset[i] != ',' || inParens) {
+				if srcset[i] == '(' {
+					inParens = true
+				} else if srcset[i] == ')' {
+					inParens = false
+				}
+				i++
+			}
+		}
+		if u != "" {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// GetMetaRefresh returns the absolute target of a meta http-equiv refresh
+func GetMetaRefresh(doc *goquery.Document, baseUrl *url.URL) string {
+	refresh := ""
+	doc.Find("meta[http-equiv]").EachWithBreak(func(i int, s *goquery.Selection) bool {
+		equiv, _ := s.Attr("http-equiv")
+		if !strings.EqualFold(equiv, "refresh") {
+			return true
+		}
+		content, _ := s.Attr("content")
+		if target, ok := ParseMetaRefresh(content); ok {
+			refresh = ToAbsUrl(baseUrl, target)
+		}
+		return false
+	})
+	return refresh
+}
+
+// ParseMetaRefresh returns the target of a refresh like "5; url=/next"
+func ParseMetaRefresh(content string) (string, bool) {
+	parts := strings.SplitN(content, ";", 2)
+	if len(parts) < 2 {
+		parts = strings.SplitN(content, ",", 2)
+	}
+	if len(parts) < 2 {
+		return "", false
+	}
+	target := strings.TrimSpace(parts[1])
+	if len(target) > 3 && strings.EqualFold(target[:3], "url") {
+		target = strings.TrimSpace(target[3:])
+		target = strings.TrimSpace(strings.TrimPrefix(target, "="))
+	}
+	target = strings.Trim(target, `'"`)
+	return target, target != ""
+}
+
+// GetUrlsFromCss returns url() and @import references of a stylesheet
+func GetUrlsFromCss(css string) []string {
+	urls := []string{}
+	for _, res := range GetRessourcesFromCss(css, nil) {
+		urls = append(urls, res.Url)
+	}
+	return urls
+}
+
+// GetRessourcesFromCss returns the references of a stylesheet, imported
+// stylesheets have the type import
+func GetRessourcesFromCss(css string, baseUrl *url.URL) []Ressource {
+	ressources := []Ressource{}
+	for _, m := range regFindCssImport.FindAllStringSubmatch(css, -1) {
+		ressources = append(ressources, Ressource{Tag: "css", Type: "import", Url: ToAbsUrl(baseUrl, m[1]+m[2])})
+	}
+	for _, m := range regFindCssUrl.FindAllStringSubmatch(css, -1) {
+		u := strings.TrimSpace(m[1] + m[2] + m[3])
+		if u == "" || strings.HasPrefix(u, "data:") || strings.HasPrefix(u, "#") {
+			continue
+		}
+		ressources = append(ressources, Ressource{Tag: "css", Type: "url", Url: ToAbsUrl(baseUrl, u)})
+	}
+	return ressources
+}
+
+// FetchStylesheetRessources downloads the linked stylesheets of a page and
+// adds their url() and @import references to the ressources
+func (c *Crawler) FetchStylesheetRessources(page *Page) {
+	cssUrls := []string{}
+	for _, res := range page.RespInfo.Ressources {
+		if (res.Tag == "link" && strings.Contains(strings.ToLower(res.Rel), "stylesheet")) ||
+			res.Type == "import" {
+			cssUrls = append(cssUrls, res.Url)
+		}
+	}
+
+	for _, cssUrl := range cssUrls {
+		sheet, err := c.GetStylesheet(cssUrl)
+		if err != nil {
+			log.Println("FetchStylesheetRessources ", err)
+			continue
+		}
+		for _, res := range sheet.Ressources {
+			res.Source = cssUrl
+			page.RespInfo.Ressources = append(page.RespInfo.Ressources, res)
+		}
+	}
+}
+
+// Stylesheet keeps the references and rules of a fetched stylesheet
+type Stylesheet struct {
+	Url        string
+	Ressources []Ressource
+	Rules      StyleRules
+}
+
+// number of parsed stylesheets a crawler keeps
+const maxStylesheetCache = 1000
+
+// GetStylesheet fetches and parses a stylesheet once per crawler, the css
+// itself is not kept
+func (c *Crawler) GetStylesheet(cssUrl string) (*Stylesheet, error) {
+	if c.stylesheets == nil || len(c.stylesheets) >= maxStylesheetCache {
+		c.stylesheets = map[string]*Stylesheet{}
+	}
+	if sheet, ok := c.stylesheets[cssUrl]; ok {
+		return sheet, nil
+	}
+
+	cssPage, err := c.GetPage(cssUrl, "GET")
+	if err != nil {
+		return nil, err
+	}
+	parsedUrl, err := url.Parse(cssUrl)
+	if err != nil {
+		return nil, err
+	}
+	css := string(cssPage.ResponseBody)
+	sheet := &Stylesheet{Url: cssUrl, Ressources: GetRessourcesFromCss(css, parsedUrl), Rules: ParseStyleRules(css)}
+	c.stylesheets[cssUrl] = sheet
+	return sheet, nil
+}
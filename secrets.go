@@ -0,0 +1,311 @@
+package crawlbase
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// SecretRule matches a secret, the Group submatch is the secret itself.
+// Matches with a lower shannon entropy than MinEntropy are ignored.
+type SecretRule struct {
+	Name       string
+	Pattern    string
+	Group      int
+	MinEntropy float64
+	regex      *regexp.Regexp
+}
+
+type SecretFinding struct {
+	Rule     string
+	Url      string
+	Location string // body, header:<name>, or the source file of a source map
+	Line     int
+	Match    string // redacted
+	Hash     string // hmac of the secret with the key of the scanner, to correlate findings
+	Entropy  float64
+}
+
+// SecretScanner searches response bodies, headers and scripts for
+// credentials. Matches of Allowlist and urls of AllowUrls are skipped.
+// Rules are compiled by SetRules or LoadSecretRules.
+type SecretScanner struct {
+	Rules     []SecretRule
+	Allowlist []*regexp.Regexp
+	AllowUrls []*regexp.Regexp
+	hashKey   []byte
+	keyOnce   sync.Once
+}
+
+var DefaultSecretRules = []SecretRule{
+	mustSecretRule("aws-access-key", `\b((?:AKIA|ASIA)[0-9A-Z]{16})\b`, 1, 0),
+	mustSecretRule("aws-secret-key", `(?i)aws.{0,20}(?:secret|key).{0,20}?['"]([A-Za-z0-9/+=]{40})['"]`, 1, 4),
+	mustSecretRule("gcp-api-key", `\b(AIza[0-9A-Za-z_-]{35})`, 1, 0),
+	mustSecretRule("gcp-service-account", `("private_key_id"\s*:\s*"[0-9a-f]{40}")`, 1, 0),
+	mustSecretRule("azure-storage-key", `(DefaultEndpointsProtocol=https?;AccountName=[^;\s]+;AccountKey=[A-Za-z0-9+/=]{40,})`, 1, 0),
+	mustSecretRule("github-token", `\b((?:ghp|gho|ghu|ghs|ghr)_[A-Za-z0-9]{36}|github_pat_[A-Za-z0-9_]{82})\b`, 1, 0),
+	mustSecretRule("slack-token", `\b(xox[baprs]-[0-9A-Za-z-]{10,})`, 1, 0),
+	mustSecretRule("slack-webhook", `(https://hooks\.slack\.com/services/T[A-Za-z0-9]+/B[A-Za-z0-9]+/[A-Za-z0-9]+)`, 1, 0),
+	mustSecretRule("stripe-key", `\b((?:sk|rk)_live_[0-9a-zA-Z]{24,})`, 1, 0),
+	mustSecretRule("private-key", `(-----BEGIN (?:RSA |EC |DSA |OPENSSH |PGP |ENCRYPTED )?PRIVATE KEY(?: BLOCK)?-----)`, 1, 0),
+	mustSecretRule("jwt", `\b(eyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,})`, 1, 0),
+	mustSecretRule("connection-string", `\b((?:mongodb(?:\+srv)?|postgres(?:ql)?|mysql|mssql|redis|amqps?)://[^\s:@/'"]+:[^\s@/'"]+@[^\s'"<]+)`, 1, 0),
+	mustSecretRule("url-credentials", `\bhttps?://([^\s:@/'"]+:[^\s@/'"]+)@`, 1, 0),
+	mustSecretRule("generic-secret", `(?i)(?:api[_-]?key|apikey|secret|token|passwd|password|auth)["']?\s*[:=]\s*["']([A-Za-z0-9_\-+/=.]{16,})["']`, 1, 3.5),
+}
+
+// placeholders in docs and examples
+var DefaultSecretAllowlist = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)example|dummy|placeholder|changeme|your[_-]|xxxx|\*\*\*\*|\$\{|\{\{`),
+}
+
+func mustSecretRule(name, pattern string, group int, minEntropy float64) SecretRule {
+	rule := SecretRule{Name: name, Pattern: pattern, Group: group, MinEntropy: minEntropy}
+	err := rule.compile()
+	if err != nil {
+		panic(err)
+	}
+	return rule
+}
+
+// compile checks the pattern and that it has the Group submatch
+func (r *SecretRule) compile() error {
+	if r.regex != nil {
+		return nil
+	}
+	regex, err := regexp.Compile(r.Pattern)
+	if err != nil {
+		return fmt.Errorf("secret rule %s: %v", r.Name, err)
+	}
+	if r.Group < 0 || r.Group > regex.NumSubexp() {
+		return fmt.Errorf("secret rule %s: no submatch %d", r.Name, r.Group)
+	}
+	r.regex = regex
+	return nil
+}
+
+// SetRules compiles copies of rules and uses them for scanning, the rules
+// are not changed if one is invalid
+func (s *SecretScanner) SetRules(rules []SecretRule) error {
+	compiled := []SecretRule{}
+	for _, rule := range rules {
+		err := rule.compile()
+		if err != nil {
+			return err
+		}
+		compiled = append(compiled, rule)
+	}
+	s.Rules = compiled
+	return nil
+}
+
+// LoadSecretRules reads a json list of rules
+func LoadSecretRules(file string) ([]SecretRule, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	rules := []SecretRule{}
+	err = json.Unmarshal(data, &rules)
+	if err != nil {
+		return nil, err
+	}
+	for i := range rules {
+		err = rules[i].compile()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
+}
+
+func NewSecretScanner() *SecretScanner {
+	return &SecretScanner{
+		Rules:     DefaultSecretRules,
+		Allowlist: DefaultSecretAllowlist,
+		AllowUrls: []*regexp.Regexp{},
+	}
+}
+
+// ShannonEntropy returns the entropy of s in bits per character
+func ShannonEntropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	counts := map[rune]int{}
+	total := 0
+	for _, r := range s {
+		counts[r]++
+		total++
+	}
+	entropy := 0.0
+	for _, c := range counts {
+		p := float64(c) / float64(total)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// RedactSecret keeps the first 4 characters, short secrets are hidden
+// completely
+func RedactSecret(secret string) string {
+	if len(secret) < 12 {
+		return strings.Repeat("*", len(secret))
+	}
+	return secret[:4] + strings.Repeat("*", len(secret)-4)
+}
+
+// ScanText returns the findings of text, location describes where text is
+// part of the response at url
+func (s *SecretScanner) ScanText(text, url, location string) []SecretFinding {
+	findings := []SecretFinding{}
+	for _, allow := range s.AllowUrls {
+		if allow.MatchString(url) {
+			return findings
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, rule := range s.Rules {
+		// rules which were not compiled by SetRules or LoadSecretRules are
+		// skipped
+		if rule.regex == nil {
+			continue
+		}
+		for _, m := range rule.regex.FindAllStringSubmatchIndex(text, -1) {
+			if 2*rule.Group+1 >= len(m) || m[2*rule.Group] < 0 {
+				continue
+			}
+			start, end := m[2*rule.Group], m[2*rule.Group+1]
+			secret := text[start:end]
+			if s.isAllowed(secret) {
+				continue
+			}
+			entropy := ShannonEntropy(secret)
+			if entropy < rule.MinEntropy {
+				continue
+			}
+			key := rule.Name + secret
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			findings = append(findings, SecretFinding{
+				Rule:     rule.Name,
+				Url:      url,
+				Location: location,
+				Line:     strings.Count(text[:start], "\n") + 1,
+				Match:    RedactSecret(secret),
+				Hash:     s.hashSecret(secret),
+				Entropy:  math.Round(entropy*100) / 100,
+			})
+		}
+	}
+	return findings
+}
+
+// hashSecret returns a hmac of secret with a random key of the scanner, a
+// plain hash of short secrets could be reversed by brute force
+func (s *SecretScanner) hashSecret(secret string) string {
+	s.keyOnce.Do(func() {
+		s.hashKey = make([]byte, 32)
+		_, err := rand.Read(s.hashKey)
+		if err != nil {
+			log.Fatal(err)
+		}
+	})
+	mac := hmac.New(sha256.New, s.hashKey)
+	mac.Write([]byte(secret))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *SecretScanner) isAllowed(secret string) bool {
+	for _, allow := range s.Allowlist {
+		if allow.MatchString(secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// ScanHeader scans the values of all headers
+func (s *SecretScanner) ScanHeader(header http.Header, url string) []SecretFinding {
+	findings := []SecretFinding{}
+	keys := []string{}
+	for k := range header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range header[k] {
+			findings = append(findings, s.ScanText(k+": "+v, url, "header:"+k)...)
+		}
+	}
+	return findings
+}
+
+// ScanPage scans the response headers and the body of text responses
+func (s *SecretScanner) ScanPage(page *Page) []SecretFinding {
+	findings := []SecretFinding{}
+	mime := ""
+	if page.Response != nil {
+		mime = page.Response.ContentMIME
+		findings = append(findings, s.ScanHeader(page.Response.Header, page.URL)...)
+	}
+	if mime == "" || isTextMime(mime) {
+		findings = append(findings, s.ScanText(string(page.ResponseBody), page.URL, "body")...)
+	}
+	return findings
+}
+
+func (c *Crawler) secretScanner() *SecretScanner {
+	if c.SecretScanner == nil {
+		c.SecretScanner = NewSecretScanner()
+	}
+	return c.SecretScanner
+}
+
+// ScanPageSecrets stores the findings of a page on the page and in the
+// report of the crawler
+func (c *Crawler) ScanPageSecrets(page *Page) {
+	page.Secrets = c.secretScanner().ScanPage(page)
+	c.addSecrets(page.Secrets)
+}
+
+func (c *Crawler) addSecrets(findings []SecretFinding) {
+	c.secrets = append(c.secrets, findings...)
+}
+
+// SecretReport returns all findings of the crawl, including fetched scripts
+// and reconstructed sources, ordered by rule, url and location
+func (c *Crawler) SecretReport() []SecretFinding {
+	report := append([]SecretFinding{}, c.secrets...)
+	sort.SliceStable(report, func(i, j int) bool {
+		if report[i].Rule != report[j].Rule {
+			return report[i].Rule < report[j].Rule
+		}
+		if report[i].Url != report[j].Url {
+			return report[i].Url < report[j].Url
+		}
+		return report[i].Location < report[j].Location
+	})
+	return report
+}
+
+func (c *Crawler) WriteSecretReport(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(c.SecretReport())
+}
@@ -0,0 +1,142 @@
+package crawlbase
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+const sourcesFolder = "sources"
+
+type SourceMap struct {
+	Version        int       `json:"version"`
+	File           string    `json:"file"`
+	SourceRoot     string    `json:"sourceRoot"`
+	Sources        []string  `json:"sources"`
+	SourcesContent []*string `json:"sourcesContent"`
+}
+
+var regSourcePathPrefix *regexp.Regexp = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*:/*`)
+
+func ParseSourceMap(data []byte) (*SourceMap, error) {
+	// maps may start with an xssi guard
+	data = bytes.TrimPrefix(bytes.TrimSpace(data), []byte(")]}'"))
+	sm := &SourceMap{}
+	err := json.Unmarshal(data, sm)
+	if err != nil {
+		return nil, err
+	}
+	return sm, nil
+}
+
+// SourceFiles maps the cleaned relative path of every source with
+// embedded content to the content
+func (sm *SourceMap) SourceFiles() map[string]string {
+	files := map[string]string{}
+	for i, source := range sm.Sources {
+		if i >= len(sm.SourcesContent) || sm.SourcesContent[i] == nil {
+			continue
+		}
+		files[CleanSourcePath(sm.SourceRoot+source)] = *sm.SourcesContent[i]
+	}
+	return files
+}
+
+// CleanSourcePath turns source names like webpack:///./src/app.js into a
+// relative path which can not leave the target folder
+func CleanSourcePath(source string) string {
+	p := regSourcePathPrefix.ReplaceAllString(source, "")
+	p = strings.Replace(p, "~/", "node_modules/", 1)
+	p = strings.Replace(p, "..", "__", -1)
+	p = strings.TrimLeft(path.Clean("/"+p), "/")
+	if p == "" {
+		p = "unnamed"
+	}
+	return p
+}
+
+// ReconstructSources writes the embedded sources below folder and returns
+// the written files
+func ReconstructSources(sm *SourceMap, folder string) ([]string, error) {
+	written := []string{}
+	for name, content := range sm.SourceFiles() {
+		filePath := path.Join(folder, name)
+		err := os.MkdirAll(path.Dir(filePath), 0777)
+		if err != nil {
+			return written, err
+		}
+		err = ioutil.WriteFile(filePath, []byte(content), 0666)
+		if err != nil {
+			return written, err
+		}
+		written = append(written, filePath)
+	}
+	return written, nil
+}
+
+// sourceMapUrl returns the source map of a fetched script, from the
+// SourceMap header or the sourceMappingURL comment
+func sourceMapUrl(scriptPage *Page, endpoints []Ressource, scriptUrl *url.URL) string {
+	if scriptPage.Response != nil && scriptPage.Response.Header != nil {
+		for _, h := range []string{"SourceMap", "X-SourceMap"} {
+			if v := scriptPage.Response.Header.Get(h); v != "" {
+				return ToAbsUrl(scriptUrl, v)
+			}
+		}
+	}
+	for _, e := range endpoints {
+		if e.Type == "sourcemap" {
+			return e.Url
+		}
+	}
+	return ""
+}
+
+// FetchSourceMap downloads a source map, writes the original sources to
+// the storage folder and extracts endpoints from them. The first returned
+// ressource is the source map itself.
+func (c *Crawler) FetchSourceMap(mapUrl string, scriptUrl *url.URL, pageUrl *url.URL) ([]Ressource, error) {
+	mapPage, err := c.GetPage(mapUrl, "GET")
+	if err != nil {
+		return nil, err
+	}
+	if mapPage.Response.StatusCode != 200 {
+		return nil, nil
+	}
+
+	sm, err := ParseSourceMap(mapPage.ResponseBody)
+	if err != nil {
+		return nil, err
+	}
+
+	found := []Ressource{{Url: mapUrl, Type: "application/json", Tag: "sourcemap", Source: scriptUrl.String()}}
+	if c.StorageFolder != "" {
+		mapFile := strings.TrimSuffix(path.Base(mapPage.URL), ".map")
+		// sources belong to the host of the script, which may be a cdn
+		folder := path.Join(c.StorageFolder, sourcesFolder, CleanSourcePath(strings.Replace(scriptUrl.Host, ":", "_", -1)), CleanSourcePath(mapFile))
+		_, err = ReconstructSources(sm, folder)
+		if err != nil {
+			log.Println("FetchSourceMap ", err)
+		}
+	}
+
+	for name, content := range sm.SourceFiles() {
+		if c.ScanSecrets {
+			c.addSecrets(c.secretScanner().ScanText(content, mapUrl, name))
+		}
+		for _, e := range ExtractJSEndpoints([]byte(content), scriptUrl, pageUrl) {
+			if e.Type == "sourcemap" {
+				continue
+			}
+			e.Source = mapUrl + "#" + name
+			found = append(found, e)
+		}
+	}
+	return found, nil
+}
@@ -0,0 +1,75 @@
+package crawlbase
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestCleanSourcePath(t *testing.T) {
+	paths := map[string]string{
+		"webpack:///./src/app.js":  "src/app.js",
+		"webpack:///../etc/passwd": "__/etc/passwd",
+		"/abs/file.ts":             "abs/file.ts",
+		"~/lodash/index.js":        "node_modules/lodash/index.js",
+	}
+	for source, expected := range paths {
+		if p := CleanSourcePath(source); p != expected {
+			t.Error("incorrect path for ", source, ": ", p)
+		}
+	}
+}
+
+func TestFetchSourceMap(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/app.js":
+			w.Write([]byte("var a=1;\n//# sourceMappingURL=app.js.map"))
+		case "/app.js.map":
+			w.Write([]byte(`)]}'
+{"version":3,"sources":["webpack:///./src/api.js"],"sourcesContent":["// internal\nfetch('/internal/admin')"]}`))
+		}
+	}))
+	defer ts.Close()
+
+	folder, err := ioutil.TempDir("", "storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+
+	cw := NewCrawler()
+	cw.StorageFolder = folder
+	cw.FetchSourceMaps = true
+	// the page is on another host than its script
+	pageUrl := strings.Replace(ts.URL, "127.0.0.1", "localhost", 1)
+	page := &Page{URL: pageUrl + "/"}
+	page.RespInfo.Ressources = []Ressource{{Url: ts.URL + "/app.js", Tag: "script"}}
+	cw.FetchScriptEndpoints(page)
+
+	hasMap := false
+	for _, res := range page.RespInfo.Ressources {
+		hasMap = hasMap || res.Tag == "sourcemap"
+	}
+	if !hasMap {
+		t.Error("source map not recorded as ressource")
+	}
+
+	hasEndpoint := false
+	for _, req := range page.RespInfo.Requests {
+		hasEndpoint = hasEndpoint || (req.Url == pageUrl+"/internal/admin" && req.Type == "fetch")
+	}
+	if !hasEndpoint {
+		t.Error("endpoint of original source missing: ", page.RespInfo.Requests)
+	}
+
+	host := strings.Replace(ts.URL[len("http://"):], ":", "_", -1)
+	content, err := ioutil.ReadFile(path.Join(folder, sourcesFolder, host, "app.js", "src", "api.js"))
+	if err != nil || len(content) == 0 {
+		t.Error("original source not written: ", err)
+	}
+}
@@ -0,0 +1,365 @@
+package crawlbase
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// TechRule identifies a technology. All values are regular expressions,
+// the first non empty submatch is the version. A rule matches if one of its
+// patterns matches.
+type TechRule struct {
+	Name          string
+	Category      string            // server, framework, cms, js-library, cdn, analytics, language
+	Headers       map[string]string `json:",omitempty"` // header name to value pattern
+	Cookies       map[string]string `json:",omitempty"` // cookie name to value pattern
+	Meta          map[string]string `json:",omitempty"` // meta name to content pattern, eg generator
+	Scripts       []string          `json:",omitempty"` // script url patterns
+	Html          []string          `json:",omitempty"`
+	FaviconHashes []string          `json:",omitempty"` // ToHash of the favicon
+	compiled      map[string]*regexp.Regexp
+}
+
+type Technology struct {
+	Name     string
+	Category string
+	Version  string `json:",omitempty"`
+	Evidence string // where the rule matched, eg header:Server
+}
+
+type SiteTechnologies struct {
+	Host         string
+	Technologies []Technology
+}
+
+// Fingerprinter detects technologies of pages with a rule set
+type Fingerprinter struct {
+	Rules []TechRule
+}
+
+var DefaultTechRules = []TechRule{
+	{Name: "nginx", Category: "server", Headers: map[string]string{"Server": `(?i)nginx(?:/([\d.]+))?`}},
+	{Name: "Apache", Category: "server", Headers: map[string]string{"Server": `(?i)apache(?:/([\d.]+))?`}},
+	{Name: "IIS", Category: "server", Headers: map[string]string{"Server": `(?i)microsoft-iis(?:/([\d.]+))?`}},
+	{Name: "Cloudflare", Category: "cdn", Headers: map[string]string{"Server": `(?i)^cloudflare$`, "Cf-Ray": ``}, Cookies: map[string]string{"__cf_bm": ``}},
+	{Name: "PHP", Category: "language", Headers: map[string]string{"X-Powered-By": `(?i)php(?:/([\d.]+))?`}, Cookies: map[string]string{"PHPSESSID": ``}},
+	{Name: "ASP.NET", Category: "framework", Headers: map[string]string{"X-Aspnet-Version": `([\d.]+)`, "X-Powered-By": `(?i)asp\.net`}, Cookies: map[string]string{"ASP.NET_SessionId": ``}, Html: []string{`<input[^>]+name="__VIEWSTATE"`}},
+	{Name: "Java", Category: "language", Cookies: map[string]string{"JSESSIONID": ``}},
+	{Name: "Express", Category: "framework", Headers: map[string]string{"X-Powered-By": `(?i)^express$`}},
+	{Name: "Next.js", Category: "framework", Headers: map[string]string{"X-Powered-By": `(?i)next\.js(?: ([\d.]+))?`}, Html: []string{`<script id="__NEXT_DATA__"`}},
+	{Name: "Laravel", Category: "framework", Cookies: map[string]string{"laravel_session": ``}},
+	{Name: "Django", Category: "framework", Html: []string{`name="csrfmiddlewaretoken"`}},
+	{Name: "WordPress", Category: "cms", Meta: map[string]string{"generator": `(?i)wordpress ?([\d.]+)?`}, Html: []string{`/wp-content/`, `/wp-includes/`}},
+	{Name: "Drupal", Category: "cms", Headers: map[string]string{"X-Generator": `(?i)drupal ?(\d+)?`}, Meta: map[string]string{"generator": `(?i)drupal ?(\d+)?`}, Html: []string{`/sites/default/files/`}},
+	{Name: "Joomla", Category: "cms", Meta: map[string]string{"generator": `(?i)joomla!? ?([\d.]+)?`}},
+	{Name: "Shopify", Category: "cms", Headers: map[string]string{"X-Shopid": ``}, Scripts: []string{`cdn\.shopify\.com`}},
+	{Name: "jQuery", Category: "js-library", Scripts: []string{`jquery[.-]([\d]+(?:\.[\d]+)+)(?:\.min)?\.js`, `/jquery/([\d]+(?:\.[\d]+)+)/`, `jquery(?:\.min)?\.js`}},
+	{Name: "React", Category: "js-library", Scripts: []string{`react(?:-dom)?(?:\.production)?(?:\.min)?\.js`, `/react(?:-dom)?@([\d.]+)/`}, Html: []string{`data-reactroot`}},
+	{Name: "Angular", Category: "js-library", Html: []string{`ng-version="([\d.]+)"`}},
+	{Name: "AngularJS", Category: "js-library", Scripts: []string{`angular(?:\.min)?\.js`, `/angular\.?js/([\d.]+)/`}, Html: []string{`\sng-app[=\s>]`}},
+	{Name: "Vue.js", Category: "js-library", Scripts: []string{`vue(?:\.runtime)?(?:\.min)?\.js`, `/vue@([\d.]+)/`}, Html: []string{`\sdata-v-[0-9a-f]{8}`}},
+	{Name: "Bootstrap", Category: "js-library", Scripts: []string{`bootstrap(?:\.bundle)?(?:\.min)?\.js`, `/bootstrap/([\d.]+)/`}},
+	{Name: "Google Analytics", Category: "analytics", Scripts: []string{`google-analytics\.com/`, `googletagmanager\.com/gtag/js`}},
+}
+
+func init() {
+	for i := range DefaultTechRules {
+		err := DefaultTechRules[i].compile()
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+// LoadTechRules reads a json list of rules
+func LoadTechRules(file string) ([]TechRule, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	rules := []TechRule{}
+	err = json.Unmarshal(data, &rules)
+	if err != nil {
+		return nil, err
+	}
+	for i := range rules {
+		err = rules[i].compile()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
+}
+
+func (r *TechRule) compile() error {
+	if r.compiled != nil {
+		return nil
+	}
+	compiled := map[string]*regexp.Regexp{}
+	patterns := []string{}
+	for _, m := range []map[string]string{r.Headers, r.Cookies, r.Meta} {
+		for _, p := range m {
+			patterns = append(patterns, p)
+		}
+	}
+	patterns = append(patterns, r.Scripts...)
+	patterns = append(patterns, r.Html...)
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return err
+		}
+		compiled[p] = re
+	}
+	r.compiled = compiled
+	return nil
+}
+
+// match returns the version and whether pattern matches value
+func (r *TechRule) match(pattern, value string) (bool, string) {
+	m := r.compiled[pattern].FindStringSubmatch(value)
+	if m == nil {
+		return false, ""
+	}
+	for _, group := range m[1:] {
+		if group != "" {
+			return true, group
+		}
+	}
+	return true, ""
+}
+
+func NewFingerprinter() *Fingerprinter {
+	return &Fingerprinter{Rules: append([]TechRule{}, DefaultTechRules...)}
+}
+
+// Detect matches the rules against the headers, cookies, meta tags, script
+// urls and html of a page and the hash of the site favicon
+func (f *Fingerprinter) Detect(page *Page, faviconHash string) []Technology {
+	header := http.Header{}
+	cookies := map[string]string{}
+	mime := ""
+	if page.Response != nil {
+		if page.Response.Header != nil {
+			header = page.Response.Header
+		}
+		mime = page.Response.ContentMIME
+		for _, c := range page.Response.Cookies {
+			cookies[c.Name] = c.Value
+		}
+	}
+	for _, c := range (&http.Response{Header: header}).Cookies() {
+		cookies[c.Name] = c.Value
+	}
+
+	meta := map[string]string{}
+	body := ""
+	if mime == "" || mime == "text/html" {
+		body = string(page.ResponseBody)
+		doc, err := goquery.NewDocumentFromReader(bytes.NewReader(page.ResponseBody))
+		if err == nil {
+			doc.Find("meta[name][content]").Each(func(i int, s *goquery.Selection) {
+				name, _ := s.Attr("name")
+				content, _ := s.Attr("content")
+				meta[strings.ToLower(name)] = content
+			})
+		}
+	}
+	scripts := []string{}
+	for _, res := range page.RespInfo.Ressources {
+		if res.Tag == "script" && res.Url != "" {
+			scripts = append(scripts, res.Url)
+		}
+	}
+
+	techs := []Technology{}
+	for _, rule := range f.Rules {
+		// rules may be shared, rules which were not compiled by
+		// LoadTechRules are compiled on the copy for each page
+		if rule.compile() != nil {
+			continue
+		}
+		tech := Technology{Name: rule.Name, Category: rule.Category}
+		found := false
+		check := func(pattern, value, evidence string) {
+			if ok, version := rule.match(pattern, value); ok {
+				if !found {
+					tech.Evidence = evidence
+				}
+				found = true
+				if tech.Version == "" {
+					tech.Version = version
+				}
+			}
+		}
+
+		for _, name := range sortedKeys(rule.Headers) {
+			pattern := rule.Headers[name]
+			if values, ok := header[http.CanonicalHeaderKey(name)]; ok {
+				for _, v := range values {
+					check(pattern, v, "header:"+http.CanonicalHeaderKey(name))
+				}
+			}
+		}
+		for _, name := range sortedKeys(rule.Cookies) {
+			pattern := rule.Cookies[name]
+			if v, ok := cookies[name]; ok {
+				check(pattern, v, "cookie:"+name)
+			}
+		}
+		for _, name := range sortedKeys(rule.Meta) {
+			pattern := rule.Meta[name]
+			if v, ok := meta[strings.ToLower(name)]; ok {
+				check(pattern, v, "meta:"+name)
+			}
+		}
+		for _, pattern := range rule.Scripts {
+			for _, s := range scripts {
+				check(pattern, s, "script:"+s)
+			}
+		}
+		if body != "" {
+			for _, pattern := range rule.Html {
+				check(pattern, body, "html")
+			}
+		}
+		if !found && faviconHash != "" && ContainsString(rule.FaviconHashes, faviconHash) {
+			tech.Evidence = "favicon"
+			found = true
+		}
+
+		if found {
+			techs = append(techs, tech)
+		}
+	}
+	return techs
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// MergeTechnologies adds techs to a host list, known technologies get a
+// missing version
+func MergeTechnologies(known []Technology, techs []Technology) []Technology {
+	for _, t := range techs {
+		merged := false
+		for i := range known {
+			if known[i].Name == t.Name {
+				if known[i].Version == "" {
+					known[i].Version = t.Version
+				}
+				merged = true
+			}
+		}
+		if !merged {
+			known = append(known, t)
+		}
+	}
+	return known
+}
+
+// faviconHash fetches the favicon of a host once, the icon link of the page
+// is preferred over /favicon.ico
+func (c *Crawler) faviconHash(page *Page, host string) string {
+	if c.faviconHashes == nil {
+		c.faviconHashes = map[string]string{}
+	}
+	if hash, ok := c.faviconHashes[host]; ok {
+		return hash
+	}
+
+	iconUrl := ""
+	for _, res := range page.RespInfo.Ressources {
+		if res.Tag == "link" && ContainsString(strings.Fields(strings.ToLower(res.Rel)), "icon") {
+			iconUrl = res.Url
+			break
+		}
+	}
+	if iconUrl == "" {
+		pageUrl, err := url.Parse(page.URL)
+		if err != nil {
+			return ""
+		}
+		iconUrl = pageUrl.Scheme + "://" + pageUrl.Host + "/favicon.ico"
+	}
+
+	hash := ""
+	icon, err := c.GetPage(iconUrl, "GET")
+	if err == nil && icon.Response.StatusCode == 200 && len(icon.ResponseBody) > 0 {
+		hash = ToHash(string(icon.ResponseBody))
+	}
+	c.faviconHashes[host] = hash
+	return hash
+}
+
+// FingerprintPage stores the detected technologies on the page and in the
+// host report of the crawler
+func (c *Crawler) FingerprintPage(page *Page) {
+	if c.Fingerprinter == nil {
+		c.Fingerprinter = NewFingerprinter()
+	}
+	pageUrl, err := url.Parse(page.URL)
+	if err != nil {
+		return
+	}
+
+	hash := ""
+	if c.FetchFavicons {
+		hash = c.faviconHash(page, pageUrl.Host)
+	}
+	page.Technologies = c.Fingerprinter.Detect(page, hash)
+
+	if c.technologies == nil {
+		c.technologies = map[string][]Technology{}
+	}
+	c.technologies[pageUrl.Host] = MergeTechnologies(c.technologies[pageUrl.Host], page.Technologies)
+}
+
+// TechReport returns the technologies of each crawled host
+func (c *Crawler) TechReport() []SiteTechnologies {
+	return techReport(c.technologies)
+}
+
+// TechReportFromPages aggregates the technologies of stored pages per host
+func TechReportFromPages(pages []*Page) []SiteTechnologies {
+	hosts := map[string][]Technology{}
+	for _, page := range pages {
+		if u, err := url.Parse(page.URL); err == nil {
+			hosts[u.Host] = MergeTechnologies(hosts[u.Host], page.Technologies)
+		}
+	}
+	return techReport(hosts)
+}
+
+func techReport(hosts map[string][]Technology) []SiteTechnologies {
+	report := []SiteTechnologies{}
+	for host, techs := range hosts {
+		if len(techs) == 0 {
+			continue
+		}
+		report = append(report, SiteTechnologies{Host: host, Technologies: techs})
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].Host < report[j].Host })
+	return report
+}
+
+func (c *Crawler) WriteTechReport(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(c.TechReport())
+}
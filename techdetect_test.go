@@ -0,0 +1,80 @@
+package crawlbase
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestDetectTechnologies(t *testing.T) {
+	str := `<html><head><meta name="generator" content="WordPress 6.4.2">
+	<script src="https://code.jquery.com/jquery-3.7.1.min.js"></script></head>
+	<body><link rel="stylesheet" href="/wp-content/themes/x/style.css"></body></html>`
+	testUrl, _ := url.Parse("http://test.com/")
+	page := PageFromData([]byte(str), testUrl, false)
+	page.URL = testUrl.String()
+	page.Response.Header = http.Header{}
+	page.Response.Header.Set("Server", "nginx/1.25.3")
+	page.Response.Header.Set("X-Powered-By", "PHP/8.2.1")
+	page.Response.Header.Add("Set-Cookie", "PHPSESSID=abc; path=/")
+
+	f := NewFingerprinter()
+	techs := map[string]Technology{}
+	for _, tech := range f.Detect(page, "") {
+		techs[tech.Name] = tech
+	}
+	expected := map[string]string{"nginx": "1.25.3", "PHP": "8.2.1", "WordPress": "6.4.2", "jQuery": "3.7.1"}
+	for name, version := range expected {
+		if techs[name].Version != version {
+			t.Error("wrong version of ", name, ": ", techs[name])
+		}
+	}
+	if techs["WordPress"].Evidence != "meta:generator" || len(techs) != 4 {
+		t.Error("wrong technologies ", techs)
+	}
+
+	c := NewCrawler()
+	c.FingerprintPage(page)
+	other := &Page{URL: "http://test.com/a", Response: &PageResponse{Header: http.Header{"Server": {"nginx"}}}}
+	c.FingerprintPage(other)
+	report := c.TechReport()
+	if len(report) != 1 || len(report[0].Technologies) != 4 || report[0].Technologies[0].Version != "1.25.3" {
+		t.Error("wrong report ", report)
+	}
+}
+
+func TestLoadTechRules(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "tech")
+	defer os.RemoveAll(dir)
+	file := path.Join(dir, "rules.json")
+	ioutil.WriteFile(file, []byte(`[{"Name": "Acme", "Category": "framework", "Headers": {"X-Acme": "v([\\d.]+)"}, "FaviconHashes": ["abc"]},
+	{"Name": "IconOnly", "Category": "cms", "FaviconHashes": ["abc"]}]`), 0666)
+
+	rules, err := LoadTechRules(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := &Page{Response: &PageResponse{Header: http.Header{"X-Acme": {"v2.1"}}, ContentMIME: "application/json"}}
+	techs := (&Fingerprinter{Rules: rules}).Detect(page, "abc")
+	if len(techs) != 2 || techs[0].Version != "2.1" || techs[1].Evidence != "favicon" {
+		t.Error("wrong technologies ", techs)
+	}
+}
+
+func TestDetectSharedRules(t *testing.T) {
+	rules := []TechRule{{Name: "Varnish", Category: "cdn", Headers: map[string]string{"Via": `(?i)varnish`}}}
+	page := &Page{URL: "http://test.com/", Response: &PageResponse{Header: http.Header{"Via": {"1.1 varnish"}}}}
+	techs := (&Fingerprinter{Rules: rules}).Detect(page, "")
+	if len(techs) != 1 || techs[0].Name != "Varnish" {
+		t.Error("literal rule not applied ", techs)
+	}
+	if rules[0].compiled != nil {
+		t.Error("shared rule modified by detect")
+	}
+	if DefaultTechRules[0].compiled == nil {
+		t.Error("default rules not compiled")
+	}
+}
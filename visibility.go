@@ -0,0 +1,382 @@
+package crawlbase
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// StyleRule is a rule with a simple selector like .class, #id, tag or
+// tag.class
+type StyleRule struct {
+	Selector     string
+	Declarations map[string]string
+}
+
+// StyleRules are kept in source order, which decides between rules of the
+// same specificity
+type StyleRules []StyleRule
+
+type Honeypot struct {
+	PageUrl string
+	Link    Link
+}
+
+var regCssComment *regexp.Regexp = regexp.MustCompile(`(?s)/\*.*?\*/`)
+var regCssRule *regexp.Regexp = regexp.MustCompile(`([^{}]+)\{([^{}]*)\}`)
+var regSimpleSelector *regexp.Regexp = regexp.MustCompile(`^[a-zA-Z0-9]*(?:[.#][a-zA-Z0-9_-]+)*$`)
+
+// ParseStyleRules reads the top level rules with simple selectors of a
+// stylesheet, rules inside @media or @supports only apply conditionally and
+// are skipped
+func ParseStyleRules(css string) StyleRules {
+	rules := StyleRules{}
+	rules.Add(css)
+	return rules
+}
+
+// Add parses css and appends its rules
+func (rules *StyleRules) Add(css string) {
+	css = topLevelCss(regCssComment.ReplaceAllString(css, ""))
+	for _, m := range regCssRule.FindAllStringSubmatch(css, -1) {
+		decls := map[string]string{}
+		for k, v := range GetStylesCss(m[2]) {
+			decls[strings.ToLower(k)] = v
+		}
+		for _, selector := range strings.Split(m[1], ",") {
+			selector = strings.ToLower(strings.TrimSpace(selector))
+			if selector == "" || !regSimpleSelector.MatchString(selector) {
+				continue
+			}
+			*rules = append(*rules, StyleRule{Selector: selector, Declarations: decls})
+		}
+	}
+}
+
+// topLevelCss removes at-rules with their blocks, like @media, @supports or
+// @font-face, and at-rules ending with a semicolon
+func topLevelCss(css string) string {
+	b := strings.Builder{}
+	inRule := false
+	atDepth := 0
+	for i := 0; i < len(css); i++ {
+		c := css[i]
+		switch {
+		case atDepth > 0:
+			if c == '{' {
+				atDepth++
+			} else if c == '}' {
+				atDepth--
+			}
+		case inRule:
+			if c == '}' {
+				inRule = false
+			}
+			b.WriteByte(c)
+		case c == '@':
+			for i < len(css) && css[i] != ';' && css[i] != '{' {
+				i++
+			}
+			if i < len(css) && css[i] == '{' {
+				atDepth = 1
+			}
+		default:
+			if c == '{' {
+				inRule = true
+			}
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// GetDocumentStyleRules returns the rules of the style elements of a page
+func GetDocumentStyleRules(doc *goquery.Document) StyleRules {
+	rules := StyleRules{}
+	doc.Find("style").Each(func(i int, s *goquery.Selection) {
+		rules.Add(s.Text())
+	})
+	return rules
+}
+
+// elementStyles applies the matching rules by specificity and source
+// order, then the inline style of an element. Important declarations are
+// only overridden by important ones.
+func (rules StyleRules) elementStyles(s *goquery.Selection) map[string]string {
+	styles := map[string]string{}
+	tag := goquery.NodeName(s)
+	id, _ := s.Attr("id")
+	class, _ := s.Attr("class")
+	classes := strings.Fields(strings.ToLower(class))
+
+	matching := StyleRules{}
+	for _, rule := range rules {
+		if selectorMatches(rule.Selector, tag, strings.ToLower(id), classes) {
+			matching = append(matching, rule)
+		}
+	}
+	sort.SliceStable(matching, func(i, j int) bool {
+		return selectorSpecificity(matching[i].Selector) < selectorSpecificity(matching[j].Selector)
+	})
+
+	set := func(k, v string) {
+		if isImportant(styles[k]) && !isImportant(v) {
+			return
+		}
+		styles[k] = v
+	}
+	for _, rule := range matching {
+		for k, v := range rule.Declarations {
+			set(k, v)
+		}
+	}
+	if style, ok := s.Attr("style"); ok {
+		for k, v := range GetStylesCss(style) {
+			set(strings.ToLower(k), v)
+		}
+	}
+	return styles
+}
+
+func isImportant(value string) bool {
+	return strings.Contains(strings.ToLower(value), "!important")
+}
+
+// selectorSpecificity weighs ids over classes over the tag
+func selectorSpecificity(selector string) int {
+	specificity := strings.Count(selector, "#")*10000 + strings.Count(selector, ".")*100
+	if selector != "" && selector[0] != '.' && selector[0] != '#' {
+		specificity += 1
+	}
+	return specificity
+}
+
+func selectorMatches(selector, tag, id string, classes []string) bool {
+	i := strings.IndexAny(selector, ".#")
+	if i < 0 {
+		return selector == tag
+	}
+	if i > 0 && selector[:i] != tag {
+		return false
+	}
+	rest := selector[i:]
+	for rest != "" {
+		kind := rest[0]
+		rest = rest[1:]
+		name := rest
+		if j := strings.IndexAny(rest, ".#"); j >= 0 {
+			name, rest = rest[:j], rest[j:]
+		} else {
+			rest = ""
+		}
+		if kind == '#' && name != id {
+			return false
+		}
+		if kind == '.' && !ContainsString(classes, name) {
+			return false
+		}
+	}
+	return true
+}
+
+// HiddenStyle returns why css declarations hide an element
+func HiddenStyle(styles map[string]string) (bool, string) {
+	if cssValue(styles, "display") == "none" {
+		return true, "display:none"
+	}
+	if v := cssValue(styles, "visibility"); v == "hidden" || v == "collapse" {
+		return true, "visibility:hidden"
+	}
+	return boxHiddenStyle(styles)
+}
+
+func cssValue(styles map[string]string, name string) string {
+	v := strings.ToLower(styles[name])
+	return strings.TrimSpace(strings.Replace(v, "!important", "", -1))
+}
+
+// boxHiddenStyle checks the size, opacity and position of an element
+func boxHiddenStyle(styles map[string]string) (bool, string) {
+	value := func(name string) string {
+		return cssValue(styles, name)
+	}
+	if v := value("opacity"); v != "" && cssNumber(v) == 0 {
+		return true, "opacity:0"
+	}
+	if v := value("width"); v != "" && cssNumber(v) == 0 {
+		return true, "zero width"
+	}
+	if v := value("height"); v != "" && cssNumber(v) == 0 {
+		return true, "zero height"
+	}
+	if v := value("font-size"); v != "" && cssNumber(v) == 0 {
+		return true, "zero font-size"
+	}
+	if pos := value("position"); pos == "absolute" || pos == "fixed" {
+		for _, side := range []string{"left", "top", "right"} {
+			if v := value(side); v != "" && cssNumber(v) <= -999 {
+				return true, "off-screen " + side
+			}
+		}
+	}
+	if v := value("text-indent"); v != "" && cssNumber(v) <= -999 {
+		return true, "off-screen text-indent"
+	}
+	return false, ""
+}
+
+// cssNumber parses the leading number of a value like -9999px, other
+// values are 1
+func cssNumber(v string) float64 {
+	end := 0
+	for end < len(v) && strings.IndexByte("+-.0123456789", v[end]) >= 0 {
+		end++
+	}
+	f, err := strconv.ParseFloat(v[:end], 64)
+	if err != nil {
+		return 1
+	}
+	return f
+}
+
+// IsHiddenElement checks the element and its ancestors for hidden and
+// aria-hidden attributes, hidden inputs and display:none. Visibility is
+// decided by the nearest element declaring it, size, opacity and position
+// are only checked on the element itself, since children may override them.
+func IsHiddenElement(s *goquery.Selection, rules StyleRules) (bool, string) {
+	visibilityDecided := false
+	for el := s; el.Length() > 0; el = el.Parent() {
+		tag := goquery.NodeName(el)
+		if tag == "html" || tag == "#document" {
+			break
+		}
+		if _, ok := el.Attr("hidden"); ok {
+			return true, tag + " hidden attribute"
+		}
+		if ariaHidden, _ := el.Attr("aria-hidden"); strings.EqualFold(ariaHidden, "true") {
+			return true, tag + " aria-hidden"
+		}
+		if inputType, _ := el.Attr("type"); tag == "input" && strings.EqualFold(inputType, "hidden") {
+			return true, "input type=hidden"
+		}
+
+		styles := rules.elementStyles(el)
+		if cssValue(styles, "display") == "none" {
+			return true, tag + " display:none"
+		}
+		if v := cssValue(styles, "visibility"); v != "" && !visibilityDecided {
+			visibilityDecided = true
+			if v == "hidden" || v == "collapse" {
+				return true, tag + " visibility:hidden"
+			}
+		}
+
+		if el.Nodes[0] != s.Nodes[0] {
+			continue
+		}
+		for _, attr := range []string{"width", "height"} {
+			if v, ok := el.Attr(attr); ok && strings.TrimSpace(v) == "0" {
+				return true, tag + " zero " + attr
+			}
+		}
+		if hidden, reason := boxHiddenStyle(styles); hidden {
+			return true, tag + " " + reason
+		}
+	}
+	return false, ""
+}
+
+// MarkHiddenLinks sets Hidden on the links of a page using the inline and
+// the given stylesheet rules. Without includeHidden, urls which are only
+// linked hidden are removed from the hrefs.
+func MarkHiddenLinks(page *Page, rules StyleRules, includeHidden bool) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(string(page.ResponseBody)))
+	if err != nil {
+		return
+	}
+	// style elements usually follow linked stylesheets
+	docRules := append(StyleRules{}, rules...)
+	docRules = append(docRules, GetDocumentStyleRules(doc)...)
+
+	i := 0
+	doc.Find("a[href], area[href]").Each(func(j int, s *goquery.Selection) {
+		if i >= len(page.RespInfo.Links) {
+			return
+		}
+		page.RespInfo.Links[i].Hidden, page.RespInfo.Links[i].HiddenReason = IsHiddenElement(s, docRules)
+		i++
+	})
+
+	if includeHidden {
+		return
+	}
+	visible := map[string]bool{}
+	for _, l := range page.RespInfo.Links {
+		if !l.Hidden {
+			visible[l.Url] = true
+		}
+	}
+	hrefs := []string{}
+	for _, href := range page.RespInfo.Hrefs {
+		if visible[href] || !containsLink(page.RespInfo.Links, href) {
+			hrefs = append(hrefs, href)
+		}
+	}
+	page.RespInfo.Hrefs = hrefs
+}
+
+func containsLink(links []Link, u string) bool {
+	for _, l := range links {
+		if l.Url == u {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyStylesheetVisibility rechecks the links of a page with the rules of
+// its fetched stylesheets, requires FetchStylesheets
+func (c *Crawler) ApplyStylesheetVisibility(page *Page) {
+	rules := StyleRules{}
+	for _, res := range page.RespInfo.Ressources {
+		if res.Tag != "link" || !strings.Contains(strings.ToLower(res.Rel), "stylesheet") {
+			continue
+		}
+		sheet, err := c.GetStylesheet(res.Url)
+		if err != nil {
+			log.Println("ApplyStylesheetVisibility ", err)
+			continue
+		}
+		rules = append(rules, sheet.Rules...)
+	}
+	if len(rules) == 0 {
+		return
+	}
+	MarkHiddenLinks(page, rules, c.IncludeHiddenLinks)
+}
+
+// HoneypotReport lists the hidden links of pages, which are likely traps
+// for crawlers
+func HoneypotReport(pages []*Page) []Honeypot {
+	honeypots := []Honeypot{}
+	for _, page := range pages {
+		for _, l := range page.RespInfo.Links {
+			if l.Hidden {
+				honeypots = append(honeypots, Honeypot{PageUrl: page.URL, Link: l})
+			}
+		}
+	}
+	return honeypots
+}
+
+func WriteHoneypotReport(w io.Writer, pages []*Page) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(HoneypotReport(pages))
+}
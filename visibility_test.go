@@ -0,0 +1,87 @@
+package crawlbase
+
+import (
+	"bytes"
+	"net/url"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestHiddenLinks(t *testing.T) {
+	str := `<style>.trap { display: none !important } /* .x {} */ @media print { #nav { visibility:hidden } }</style>
+	<a href="/visible">ok</a>
+	<div class="box trap"><a href="/class">class</a></div>
+	<div hidden><a href="/attr">attr</a></div>
+	<a href="/aria" aria-hidden="true">aria</a>
+	<a href="/offscreen" style="position:absolute; left:-9999px">off</a>
+	<a href="/zero" style="width:0;overflow:hidden">zero</a>
+	<span class="external"><a href="/external">external</a></span>`
+	doc, _ := goquery.NewDocumentFromReader(bytes.NewReader([]byte(str)))
+	testUrl, _ := url.Parse("http://test.com/")
+
+	hrefs := GetHrefs(doc, testUrl, true)
+	if len(hrefs) != 2 || hrefs[0] != "http://test.com/visible" {
+		t.Error("wrong visible hrefs ", hrefs)
+	}
+
+	links := GetLinks(doc, testUrl)
+	hidden := 0
+	for _, l := range links {
+		if l.Hidden {
+			hidden++
+		}
+	}
+	if hidden != 5 || links[0].Hidden || links[6].Hidden {
+		t.Error("wrong hidden links ", links)
+	}
+
+	page := PageFromData([]byte(str), testUrl, false)
+	page.URL = testUrl.String()
+	MarkHiddenLinks(page, ParseStyleRules("span.external { display: none }"), false)
+	if !page.RespInfo.Links[6].Hidden || ContainsString(page.RespInfo.Hrefs, "http://test.com/external") {
+		t.Error("stylesheet rule not applied ", page.RespInfo.Links[6], page.RespInfo.Hrefs)
+	}
+
+	honeypots := HoneypotReport([]*Page{page})
+	if len(honeypots) != 6 || honeypots[0].PageUrl != page.URL {
+		t.Error("wrong honeypots ", honeypots)
+	}
+}
+
+func TestStyleRulesOrder(t *testing.T) {
+	str := `<style>#b { display: block } .a { display: none } p.c { display: none } .c { display: block }
+	.d { display: none !important } #e { display: block }</style>
+	<p class="a" id="b"><a href="/1">1</a></p>
+	<p class="c"><a href="/2">2</a></p>
+	<p class="d" id="e"><a href="/3">3</a></p>`
+	doc, _ := goquery.NewDocumentFromReader(bytes.NewReader([]byte(str)))
+	rules := GetDocumentStyleRules(doc)
+
+	expected := []bool{false, true, true}
+	doc.Find("a").Each(func(j int, s *goquery.Selection) {
+		if hidden, _ := IsHiddenElement(s, rules); hidden != expected[j] {
+			t.Error("wrong visibility of link ", j, " ", hidden)
+		}
+	})
+}
+
+func TestVisibleNavigation(t *testing.T) {
+	testUrl, _ := url.Parse("http://test.com/")
+	cases := map[string]string{
+		"media query":   `<style>@media (max-width:600px){.desktop-nav{display:none}} @supports (display:grid){a{display:none}}</style><nav class="desktop-nav"><a href="/a">a</a></nav>`,
+		"font-size":     `<style>ul.nav{font-size:0} ul.nav li{font-size:14px}</style><ul class="nav"><li><a href="/a">a</a></li></ul>`,
+		"visible child": `<div style="visibility:hidden"><p style="visibility:visible"><a href="/a">a</a></p></div>`,
+	}
+	for name, str := range cases {
+		page := PageFromData([]byte(str), testUrl, false)
+		if len(page.RespInfo.Hrefs) != 1 || page.RespInfo.Hrefs[0] != "http://test.com/a" {
+			t.Error(name, " link dropped ", page.RespInfo.Hrefs)
+		}
+	}
+
+	page := PageFromData([]byte(`<div style="visibility:hidden"><p><a href="/a">a</a></p></div>`), testUrl, false)
+	if len(page.RespInfo.Hrefs) != 0 {
+		t.Error("inherited visibility:hidden not applied ", page.RespInfo.Hrefs)
+	}
+}